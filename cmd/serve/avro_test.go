@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestAvroRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	runSteps(t, app, []step{
+		{http.MethodGet, "/schemas/user/avro", "", http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/user?format=avro", `{"type": "record"}`, http.StatusUnprocessableEntity, nil},
+		{http.MethodPost, "/schemas/user?format=avro", `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}]}`, http.StatusCreated, []string{`"title":"User"`, `"required":["id"]`}},
+		{http.MethodPut, "/schemas/user?format=avro", `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}, {"name": "mail", "type": ["null", "string"], "default": null}]}`, http.StatusOK, []string{`"Version":2`}},
+		{http.MethodGet, "/schemas/user/avro", "", http.StatusOK, []string{`"name":"User"`, `"name":"mail"`}},
+		{http.MethodGet, "/schemas/user/avro?version=1", "", http.StatusOK, []string{`"name":"User"`}},
+		{http.MethodGet, "/schemas/user/avro?version=3", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/user/avro?version=x", "", http.StatusNotFound, nil},
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+type compatibilityResponse struct {
+	IsCompatible bool     `json:"is_compatible"`
+	Messages     []string `json:"messages"`
+}
+
+// handleTestCompatibility runs the checks of handleUpdateSchema against the
+// given version without storing anything.
+func (a *App) handleTestCompatibility(c echo.Context) error {
+	version := service.LatestVersion
+	if c.Param("version") != "latest" {
+		var err error
+		version, err = strconv.Atoi(c.Param("version"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "schema not found"})
+		}
+	}
+
+	requestBody, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// The schema type defaults to the one of the newest version, drafts
+	// included, like in handleUpdateSchema
+	schemaType := service.JSON
+	if latest, err := a.schemaService.FindNewest(c.Param("name")); err == nil {
+		schemaType = latest.Type()
+	}
+	schema := &service.Schema{
+		Name: c.Param("name"),
+	}
+	schema.SchemaType, err = requestSchemaType(c, schemaType)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+
+	if err := schema.Parse(requestBody); err != nil {
+		return c.JSON(http.StatusOK, compatibilityResponse{
+			IsCompatible: false,
+			Messages:     []string{err.Error()},
+		})
+	}
+
+	_, violations, err := a.schemaService.TestCompatibility(schema, version)
+	if err != nil {
+		if err == service.ErrNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "schema not found"})
+		}
+		if errors.Is(err, service.ErrInvalidReference) {
+			return c.JSON(http.StatusOK, compatibilityResponse{
+				IsCompatible: false,
+				Messages:     []string{err.Error()},
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	messages := make([]string, 0, len(violations))
+	for _, v := range violations {
+		messages = append(messages, v.String())
+	}
+	return c.JSON(http.StatusOK, compatibilityResponse{
+		IsCompatible: len(violations) == 0,
+		Messages:     messages,
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestCompatibilityRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest", `{"type": "string"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders", `{"type": "number"}`, http.StatusCreated, nil},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest", `{"type": "number", "maximum": 10}`, http.StatusOK,
+			[]string{`"is_compatible":false`, `CONSTRAINT_TIGHTENED at #: maximum changed from none to 10`}},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/1", `{"type": "number", "description": "amount"}`, http.StatusOK,
+			[]string{`"is_compatible":true`, `"messages":[]`}},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/2", `{"type": "number"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/x", `{"type": "number"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest", `{"type": `, http.StatusOK, []string{`"is_compatible":false`}},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest", `{"$ref": "registry:missing/1"}`, http.StatusOK, []string{`"is_compatible":false`}},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest?schemaType=AVRO", `"string"`, http.StatusOK,
+			[]string{`"is_compatible":false`, `SCHEMA_TYPE_CHANGED`}},
+		{http.MethodPost, "/compatibility/schemas/orders/versions/latest?schemaType=XML", `{}`, http.StatusUnprocessableEntity, nil},
+		// Nothing was stored
+		{http.MethodGet, "/schemas/orders/versions", "", http.StatusOK, []string{`"Version":1`}},
+		{http.MethodGet, "/schemas/orders/2", "", http.StatusNotFound, nil},
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+// configRequest is the body of the config updates. The config routes follow
+// the Confluent Schema Registry API, including its error bodies.
+type configRequest struct {
+	Compatibility string `json:"compatibility"`
+}
+
+// bindConfig reads a config update from the request body.
+func bindConfig(c echo.Context) (*service.Config, error) {
+	req := &configRequest{}
+	if err := bindJSON(c, req); err != nil {
+		return nil, err
+	}
+	level, err := compat.ParseLevel(req.Compatibility)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Config{Compatibility: level}, nil
+}
+
+func (a *App) handleGetGlobalConfig(c echo.Context) error {
+	config, err := a.schemaService.GlobalConfig()
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, config)
+}
+
+func (a *App) handleUpdateGlobalConfig(c echo.Context) error {
+	config, err := bindConfig(c)
+	if err != nil {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidCompatibility, "%s", err)
+	}
+	if err := a.schemaService.SetGlobalConfig(config); err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, configRequest{Compatibility: string(config.Compatibility)})
+}
+
+func (a *App) handleGetConfig(c echo.Context) error {
+	var config *service.Config
+	var err error
+	if c.QueryParam("defaultToGlobal") == "true" {
+		config, err = a.schemaService.EffectiveConfig(c.Param("name"))
+	} else {
+		config, err = a.schemaService.FindConfig(c.Param("name"))
+	}
+	if err != nil {
+		if err == service.ErrConfigNotFound {
+			return confluentErr(c, http.StatusNotFound, errSubjectConfigNotFound, "Subject '%s' does not have subject-level compatibility configured", c.Param("name"))
+		}
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, config)
+}
+
+func (a *App) handleUpdateConfig(c echo.Context) error {
+	config, err := bindConfig(c)
+	if err != nil {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidCompatibility, "%s", err)
+	}
+	if err := a.schemaService.SetConfig(c.Param("name"), config); err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, configRequest{Compatibility: string(config.Compatibility)})
+}
+
+func (a *App) handleDeleteConfig(c echo.Context) error {
+	config, err := a.schemaService.DeleteConfig(c.Param("name"))
+	if err != nil {
+		if err == service.ErrConfigNotFound {
+			return confluentErr(c, http.StatusNotFound, errSubjectConfigNotFound, "Subject '%s' does not have subject-level compatibility configured", c.Param("name"))
+		}
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, config)
+}
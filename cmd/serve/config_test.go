@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestConfigRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	runSteps(t, app, []step{
+		{http.MethodGet, "/config", "", http.StatusOK, []string{`"compatibilityLevel":"BACKWARD"`}},
+		{http.MethodPut, "/config", `{"compatibility": "full"}`, http.StatusOK, []string{`"compatibility":"FULL"`}},
+		{http.MethodPut, "/config", `{"compatibility": "SIDEWAYS"}`, http.StatusUnprocessableEntity, []string{`"error_code":42203`}},
+		{http.MethodGet, "/config", "", http.StatusOK, []string{`"compatibilityLevel":"FULL"`}},
+		{http.MethodGet, "/config/orders", "", http.StatusNotFound, []string{`"error_code":40408`}},
+		{http.MethodGet, "/config/orders?defaultToGlobal=true", "", http.StatusOK, []string{`"compatibilityLevel":"FULL"`}},
+		{http.MethodPut, "/config/orders", `{"compatibility": "NONE"}`, http.StatusOK, []string{`"compatibility":"NONE"`}},
+		{http.MethodGet, "/config/orders", "", http.StatusOK, []string{`"compatibilityLevel":"NONE"`}},
+
+		// The per-name level applies to new versions
+		{http.MethodPost, "/schemas/orders", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "integer"}`, http.StatusOK, nil},
+		{http.MethodDelete, "/config/orders", "", http.StatusOK, []string{`"compatibilityLevel":"NONE"`}},
+		{http.MethodDelete, "/config/orders", "", http.StatusNotFound, []string{`"error_code":40408`}},
+		{http.MethodPut, "/schemas/orders", `{"type": "boolean"}`, http.StatusConflict, nil},
+	})
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+// Error codes of the Confluent Schema Registry API.
+const (
+	errSubjectNotFound       = 40401
+	errVersionNotFound       = 40402
+	errSchemaNotFound        = 40403
+	errSubjectConfigNotFound = 40408
+	errIncompatibleSchema    = 409
+	errInvalidSchema         = 42201
+	errInvalidVersion        = 42202
+	errInvalidCompatibility  = 42203
+	errInvalidSubject        = 42208
+	errInternal              = 50001
+)
+
+type confluentError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+type confluentReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// confluentSchemaRequest is the body of the register and lookup requests. A
+// missing schemaType means AVRO, as in the Confluent API.
+type confluentSchemaRequest struct {
+	Schema     string               `json:"schema"`
+	SchemaType string               `json:"schemaType,omitempty"`
+	References []confluentReference `json:"references,omitempty"`
+}
+
+type confluentSchemaResponse struct {
+	Subject    string               `json:"subject,omitempty"`
+	ID         int                  `json:"id"`
+	Version    int                  `json:"version,omitempty"`
+	SchemaType string               `json:"schemaType,omitempty"`
+	Schema     string               `json:"schema"`
+	References []confluentReference `json:"references,omitempty"`
+}
+
+// confluentSchemaByIDResponse describes a schema looked up by id, which is
+// not tied to a subject.
+type confluentSchemaByIDResponse struct {
+	Schema     string               `json:"schema"`
+	SchemaType string               `json:"schemaType,omitempty"`
+	References []confluentReference `json:"references,omitempty"`
+}
+
+type confluentIDResponse struct {
+	ID int `json:"id"`
+}
+
+func confluentErr(c echo.Context, status, code int, format string, args ...interface{}) error {
+	return c.JSON(status, confluentError{ErrorCode: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func confluentInternalErr(c echo.Context, err error) error {
+	return confluentErr(c, http.StatusInternalServerError, errInternal, "%s", err.Error())
+}
+
+// confluentSchemaErr answers 42201 for schemas that cannot be parsed or refer
+// to unknown versions, and 500 for other errors.
+func confluentSchemaErr(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrInvalidSchema) || errors.Is(err, service.ErrInvalidReference) {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSchema, "%s", err)
+	}
+	return confluentInternalErr(c, err)
+}
+
+// bindJSON decodes the request body whatever its content type, Confluent
+// clients send application/vnd.schemaregistry.v1+json.
+func bindJSON(c echo.Context, v interface{}) error {
+	return json.NewDecoder(c.Request().Body).Decode(v)
+}
+
+// confluentResponse describes a stored schema version in the Confluent format.
+func confluentResponse(schema *service.Schema) (*confluentSchemaResponse, error) {
+	text, err := schema.Text()
+	if err != nil {
+		return nil, err
+	}
+	res := &confluentSchemaResponse{
+		Subject: schema.Name,
+		ID:      schema.SchemaID,
+		Version: schema.Version,
+		Schema:  string(text),
+	}
+	if schema.Type() != service.AVRO {
+		res.SchemaType = string(schema.Type())
+	}
+	for _, ref := range schema.References {
+		res.References = append(res.References, confluentReference{
+			Name:    ref.String(),
+			Subject: ref.Name,
+			Version: ref.Version,
+		})
+	}
+	return res, nil
+}
+
+// confluentSchema builds the schema described by a register or lookup request
+// for subject. References of JSON schemas are rewritten to registry
+// references; other schema types do not support references.
+func confluentSchema(subject string, req *confluentSchemaRequest) (*service.Schema, []byte, error) {
+	schemaType := service.AVRO
+	if req.SchemaType != "" {
+		var err error
+		schemaType, err = service.ParseSchemaType(req.SchemaType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", service.ErrInvalidSchema, err)
+		}
+	}
+	schema := &service.Schema{Name: subject, SchemaType: schemaType}
+
+	body := []byte(req.Schema)
+	if len(req.References) > 0 {
+		if schemaType != service.JSON {
+			return nil, nil, fmt.Errorf("%w: references are only supported for JSON schemas", service.ErrInvalidReference)
+		}
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", service.ErrInvalidSchema, err)
+		}
+		refs := map[string]string{}
+		for _, ref := range req.References {
+			refs[ref.Name] = service.Reference{Name: ref.Subject, Version: ref.Version}.String()
+		}
+		var err error
+		body, err = json.Marshal(rewriteRefs(doc, refs))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := schema.Parse(body); err != nil {
+		return nil, nil, err
+	}
+	return schema, body, nil
+}
+
+// rewriteRefs replaces every $ref found in refs by its registry reference.
+func rewriteRefs(node interface{}, refs map[string]string) interface{} {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if s, ok := value.(string); ok && key == "$ref" {
+				if ref, ok := refs[s]; ok {
+					node[key] = ref
+				}
+				continue
+			}
+			node[key] = rewriteRefs(value, refs)
+		}
+	case []interface{}:
+		for i, value := range node {
+			node[i] = rewriteRefs(value, refs)
+		}
+	}
+	return node
+}
+
+// findSubjectVersion returns the version of a subject given as a number,
+// "latest" or -1. If it returns no schema it has answered the request with an
+// error and its error is the result of writing that answer.
+func (a *App) findSubjectVersion(c echo.Context) (*service.Schema, error) {
+	subject := c.Param("subject")
+	versions, err := a.schemaService.FindVersions(subject, c.QueryParam("deleted") == "true")
+	if err != nil {
+		return nil, confluentInternalErr(c, err)
+	}
+	if len(versions) == 0 {
+		return nil, confluentErr(c, http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", subject)
+	}
+
+	param := c.Param("version")
+	if param == "latest" || param == "-1" {
+		// Drafts are never the latest version
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i].Lifecycle() != service.StateDraft {
+				return subjectVersion(c, versions[i])
+			}
+		}
+		return nil, confluentErr(c, http.StatusNotFound, errVersionNotFound, "Version %s not found.", param)
+	}
+	version, err := strconv.Atoi(param)
+	if err != nil || version < 1 {
+		return nil, confluentErr(c, http.StatusUnprocessableEntity, errInvalidVersion,
+			"The specified version '%s' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\"", param)
+	}
+	for _, schema := range versions {
+		if schema.Version == version {
+			return subjectVersion(c, schema)
+		}
+	}
+	return nil, confluentErr(c, http.StatusNotFound, errVersionNotFound, "Version %d not found.", version)
+}
+
+// subjectVersion answers 410 Gone for a disabled version and otherwise
+// returns it, with the deprecation headers of a deprecated one.
+func subjectVersion(c echo.Context, schema *service.Schema) (*service.Schema, error) {
+	if versionGone(c, schema) {
+		return nil, confluentErr(c, http.StatusGone, errVersionNotFound, "Version %d of subject '%s' is disabled.", schema.Version, schema.Name)
+	}
+	return schema, nil
+}
+
+func (a *App) handleGetSubjects(c echo.Context) error {
+	subjects, err := a.schemaService.FindNames(c.QueryParam("deleted") == "true")
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, subjects)
+}
+
+func (a *App) handleGetSubjectVersions(c echo.Context) error {
+	subject := c.Param("subject")
+	infos, err := a.schemaService.FindVersionInfos(subject, c.QueryParam("deleted") == "true")
+	if err == service.ErrNotFound {
+		return confluentErr(c, http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", subject)
+	} else if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	versions := make([]int, 0, len(infos))
+	for _, info := range infos {
+		versions = append(versions, info.Version)
+	}
+	return c.JSON(http.StatusOK, versions)
+}
+
+func (a *App) handleGetSubjectVersion(c echo.Context) error {
+	schema, err := a.findSubjectVersion(c)
+	if schema == nil {
+		return err
+	}
+	res, err := confluentResponse(schema)
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (a *App) handleGetSubjectVersionSchema(c echo.Context) error {
+	schema, err := a.findSubjectVersion(c)
+	if schema == nil {
+		return err
+	}
+	text, err := schema.Text()
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.Blob(http.StatusOK, "application/json", text)
+}
+
+// handleRegisterSubjectVersion registers a schema under a subject. Registering
+// a schema the subject already has returns the existing version.
+func (a *App) handleRegisterSubjectVersion(c echo.Context) error {
+	subject := c.Param("subject")
+	req := &confluentSchemaRequest{}
+	if err := bindJSON(c, req); err != nil {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %s", err)
+	}
+	schema, body, err := confluentSchema(subject, req)
+	if err != nil {
+		return confluentSchemaErr(c, err)
+	}
+
+	registered, _, err := a.schemaService.Register(schema, body, service.RegisterAny, nil)
+	if err != nil {
+		var compatErr *service.CompatibilityError
+		switch {
+		case errors.As(err, &compatErr):
+			messages := make([]string, 0, len(compatErr.Violations))
+			for _, v := range compatErr.Violations {
+				messages = append(messages, v.String())
+			}
+			return confluentErr(c, http.StatusConflict, errIncompatibleSchema,
+				"Schema being registered is incompatible with an earlier schema for subject \"%s\", details: %v", subject, messages)
+		case errors.Is(err, service.ErrSchemaExists) || errors.Is(err, service.ErrVersionExists):
+			// Concurrent registrations of other schemas kept winning
+			return confluentErr(c, http.StatusConflict, errIncompatibleSchema, "%s", err)
+		case errors.Is(err, service.ErrReservedName):
+			return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSubject, "%s", err)
+		}
+		return confluentSchemaErr(c, err)
+	}
+	return c.JSON(http.StatusOK, confluentIDResponse{ID: registered.SchemaID})
+}
+
+// handleLookupSubjectSchema returns the version of a subject holding the
+// posted schema.
+func (a *App) handleLookupSubjectSchema(c echo.Context) error {
+	subject := c.Param("subject")
+	req := &confluentSchemaRequest{}
+	if err := bindJSON(c, req); err != nil {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %s", err)
+	}
+	if _, err := a.schemaService.FindByName(subject); err != nil {
+		if err == service.ErrNotFound {
+			return confluentErr(c, http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", subject)
+		}
+		return confluentInternalErr(c, err)
+	}
+
+	schema, _, err := confluentSchema(subject, req)
+	if err != nil {
+		return confluentSchemaErr(c, err)
+	}
+	existing, err := a.schemaService.FindByContent(schema)
+	if err != nil {
+		if err == service.ErrNotFound {
+			return confluentErr(c, http.StatusNotFound, errSchemaNotFound, "Schema not found")
+		}
+		return confluentInternalErr(c, err)
+	}
+	res, err := confluentResponse(existing)
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// handleGetSchemaByID returns the schema with the given integer schema id,
+// answering 410 when the version it is served from is disabled and with the
+// deprecation headers when it is deprecated.
+func (a *App) handleGetSchemaByID(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return confluentErr(c, http.StatusNotFound, errSchemaNotFound, "Schema %s not found", c.Param("id"))
+	}
+	schema, err := a.schemaService.FindBySchemaID(id)
+	if err != nil {
+		if err == service.ErrNotFound {
+			return confluentErr(c, http.StatusNotFound, errSchemaNotFound, "Schema %s not found", c.Param("id"))
+		}
+		return confluentInternalErr(c, err)
+	}
+	if versionGone(c, schema) {
+		return confluentErr(c, http.StatusGone, errSchemaNotFound, "Schema %s is disabled", c.Param("id"))
+	}
+	res, err := confluentResponse(schema)
+	if err != nil {
+		return confluentInternalErr(c, err)
+	}
+	return c.JSON(http.StatusOK, confluentSchemaByIDResponse{
+		Schema:     res.Schema,
+		SchemaType: res.SchemaType,
+		References: res.References,
+	})
+}
+
+// handleTestSubjectCompatibility is the Confluent form of
+// handleTestCompatibility.
+func (a *App) handleTestSubjectCompatibility(c echo.Context) error {
+	subject := c.Param("subject")
+	version := service.LatestVersion
+	if param := c.Param("version"); param != "latest" && param != "-1" {
+		var err error
+		version, err = strconv.Atoi(param)
+		if err != nil || version < 1 {
+			return confluentErr(c, http.StatusUnprocessableEntity, errInvalidVersion,
+				"The specified version '%s' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\"", param)
+		}
+	}
+	req := &confluentSchemaRequest{}
+	if err := bindJSON(c, req); err != nil {
+		return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %s", err)
+	}
+	schema, _, err := confluentSchema(subject, req)
+	if err != nil {
+		return confluentSchemaErr(c, err)
+	}
+
+	_, violations, err := a.schemaService.TestCompatibility(schema, version)
+	if err != nil {
+		if err == service.ErrNotFound {
+			if _, err := a.schemaService.FindByName(subject); err == service.ErrNotFound {
+				return confluentErr(c, http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", subject)
+			}
+			return confluentErr(c, http.StatusNotFound, errVersionNotFound, "Version %s not found.", c.Param("version"))
+		}
+		if errors.Is(err, service.ErrInvalidReference) {
+			return confluentErr(c, http.StatusUnprocessableEntity, errInvalidSchema, "%s", err)
+		}
+		return confluentInternalErr(c, err)
+	}
+	messages := make([]string, 0, len(violations))
+	for _, v := range violations {
+		messages = append(messages, v.String())
+	}
+	return c.JSON(http.StatusOK, compatibilityResponse{
+		IsCompatible: len(violations) == 0,
+		Messages:     messages,
+	})
+}
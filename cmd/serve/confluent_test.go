@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestConfluentRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	user := `{"schema": "{\"type\":\"record\",\"name\":\"User\",\"fields\":[{\"name\":\"id\",\"type\":\"long\"}]}"}`
+	userWithMail := `{"schema": "{\"type\":\"record\",\"name\":\"User\",\"fields\":[{\"name\":\"id\",\"type\":\"long\"},{\"name\":\"mail\",\"type\":\"string\",\"default\":\"\"}]}"}`
+	userWithStringID := `{"schema": "{\"type\":\"record\",\"name\":\"User\",\"fields\":[{\"name\":\"id\",\"type\":\"string\"}]}"}`
+	runSteps(t, app, []step{
+		{http.MethodGet, "/subjects", "", http.StatusOK, []string{`[]`}},
+		{http.MethodGet, "/subjects/user/versions", "", http.StatusNotFound, []string{`"error_code":40401`}},
+		{http.MethodPost, "/subjects/user/versions", `{`, http.StatusUnprocessableEntity, []string{`"error_code":42201`}},
+		{http.MethodPost, "/subjects/user/versions", `{"schema": "{\"type\": \"record\"}"}`, http.StatusUnprocessableEntity, []string{`"error_code":42201`}},
+		{http.MethodPost, "/subjects/user/versions", `{"schema": "{}", "schemaType": "XML"}`, http.StatusUnprocessableEntity, []string{`"error_code":42201`}},
+		{http.MethodPost, "/subjects/ids/versions", user, http.StatusUnprocessableEntity, []string{`"error_code":42208`}},
+		{http.MethodPost, "/subjects/user/versions", user, http.StatusOK, []string{`{"id":1}`}},
+		// The same schema, formatted differently, keeps its id
+		{http.MethodPost, "/subjects/user/versions", `{"schema": "{\"type\": \"record\", \"name\": \"User\", \"fields\": [{\"name\": \"id\", \"type\": \"long\"}]}"}`, http.StatusOK, []string{`{"id":1}`}},
+		{http.MethodPost, "/subjects/other/versions", user, http.StatusOK, []string{`{"id":1}`}},
+		{http.MethodPost, "/subjects/user/versions", userWithMail, http.StatusOK, []string{`{"id":2}`}},
+		{http.MethodPost, "/subjects/user/versions", userWithStringID, http.StatusConflict, []string{`"error_code":409`, `incompatible`}},
+
+		{http.MethodGet, "/subjects", "", http.StatusOK, []string{`["other","user"]`}},
+		{http.MethodGet, "/subjects/user/versions", "", http.StatusOK, []string{`[1,2]`}},
+		{http.MethodGet, "/subjects/user/versions/latest", "", http.StatusOK, []string{`"subject":"user"`, `"id":2`, `"version":2`}},
+		{http.MethodGet, "/subjects/user/versions/-1", "", http.StatusOK, []string{`"version":2`}},
+		{http.MethodGet, "/subjects/user/versions/1", "", http.StatusOK, []string{`"id":1`, `"version":1`}},
+		{http.MethodGet, "/subjects/user/versions/3", "", http.StatusNotFound, []string{`"error_code":40402`}},
+		{http.MethodGet, "/subjects/user/versions/0", "", http.StatusUnprocessableEntity, []string{`"error_code":42202`}},
+		{http.MethodGet, "/subjects/user/versions/x", "", http.StatusUnprocessableEntity, []string{`"error_code":42202`}},
+		{http.MethodGet, "/subjects/missing/versions/1", "", http.StatusNotFound, []string{`"error_code":40401`}},
+		{http.MethodGet, "/subjects/user/versions/1/schema", "", http.StatusOK, []string{`{"type":"record","name":"User"`}},
+
+		{http.MethodPost, "/subjects/user", user, http.StatusOK, []string{`"subject":"user"`, `"version":1`, `"id":1`}},
+		{http.MethodPost, "/subjects/user", userWithStringID, http.StatusNotFound, []string{`"error_code":40403`}},
+		{http.MethodPost, "/subjects/missing", user, http.StatusNotFound, []string{`"error_code":40401`}},
+
+		{http.MethodGet, "/schemas/ids/2", "", http.StatusOK, []string{`"schema":"{\"type\":\"record\"`, `mail`}},
+		{http.MethodGet, "/schemas/ids/9", "", http.StatusNotFound, []string{`"error_code":40403`}},
+		{http.MethodGet, "/schemas/ids/x", "", http.StatusNotFound, []string{`"error_code":40403`}},
+
+		{http.MethodPost, "/compatibility/subjects/user/versions/latest", userWithStringID, http.StatusOK, []string{`"is_compatible":false`}},
+		{http.MethodPost, "/compatibility/subjects/user/versions/1", userWithMail, http.StatusOK, []string{`"is_compatible":true`}},
+		{http.MethodPost, "/compatibility/subjects/user/versions/9", user, http.StatusNotFound, []string{`"error_code":40402`}},
+		{http.MethodPost, "/compatibility/subjects/user/versions/x", user, http.StatusUnprocessableEntity, []string{`"error_code":42202`}},
+		{http.MethodPost, "/compatibility/subjects/missing/versions/latest", user, http.StatusNotFound, []string{`"error_code":40401`}},
+	})
+}
+
+func TestConfluentSchemaTypes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/subjects/address/versions", `{"schemaType": "JSON", "schema": "{\"type\": \"object\", \"properties\": {\"street\": {\"type\": \"string\"}}}"}`, http.StatusOK, []string{`{"id":1}`}},
+		// References of JSON schemas become registry references
+		{http.MethodPost, "/subjects/order/versions", `{"schemaType": "JSON", "schema": "{\"type\": \"object\", \"properties\": {\"to\": {\"$ref\": \"address.json\"}}}",
+			"references": [{"name": "address.json", "subject": "address", "version": 1}]}`, http.StatusOK, []string{`{"id":2}`}},
+		{http.MethodGet, "/subjects/order/versions/1", "", http.StatusOK, []string{`"schemaType":"JSON"`, `registry:address/1`, `"references":[{"name":"registry:address/1","subject":"address","version":1}]`}},
+		{http.MethodPost, "/subjects/order/versions", `{"schemaType": "JSON", "schema": "{\"$ref\": \"address.json\"}",
+			"references": [{"name": "address.json", "subject": "address", "version": 2}]}`, http.StatusUnprocessableEntity, []string{`"error_code":42201`}},
+		{http.MethodPost, "/subjects/user/versions", `{"schema": "\"string\"", "references": [{"name": "a", "subject": "address", "version": 1}]}`, http.StatusUnprocessableEntity, []string{`"error_code":42201`}},
+
+		{http.MethodPost, "/subjects/status/versions", `{"schemaType": "PROTOBUF", "schema": "syntax = \"proto3\"; message Status { string code = 1; }"}`, http.StatusOK, []string{`{"id":3}`}},
+		{http.MethodGet, "/subjects/status/versions/latest", "", http.StatusOK, []string{`"schemaType":"PROTOBUF"`, `message Status`}},
+		{http.MethodGet, "/schemas/ids/3", "", http.StatusOK, []string{`"schemaType":"PROTOBUF"`}},
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestDeleteRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodDelete, "/schemas/s", "", http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/address", `{"type": "object", "properties": {"street": {"type": "string"}}}`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/order", `{"type": "object", "properties": {"to": {"$ref": "registry:address/1"}}}`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/s", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/s", `{"type": "integer"}`, http.StatusOK, []string{`"Version":2`}},
+	})
+
+	// Live versions do not report the soft-delete fields
+	if rec := do(app, http.MethodGet, "/schemas/s/2", ""); strings.Contains(rec.Body.String(), "Deleted") {
+		t.Errorf("live version reports deletion: %s", rec.Body)
+	}
+
+	runSteps(t, app, []step{
+		{http.MethodDelete, "/schemas/s/x", "", http.StatusNotFound, nil},
+		{http.MethodDelete, "/schemas/s/3", "", http.StatusNotFound, nil},
+		{http.MethodDelete, "/schemas/s/2?permanent=true", "", http.StatusConflict, nil},
+		{http.MethodDelete, "/schemas/s/2", "", http.StatusOK, []string{`2`}},
+		{http.MethodDelete, "/schemas/s/2", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/s/2", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/s", "", http.StatusOK, []string{`"Version":1`}},
+		{http.MethodGet, "/schemas/s/versions?deleted=true", "", http.StatusOK, []string{`"Version":2,`, `"Deleted":true`}},
+		{http.MethodGet, "/subjects/s/versions?deleted=true", "", http.StatusOK, []string{`[1,2]`}},
+		{http.MethodGet, "/schemas/ids/4", "", http.StatusOK, []string{`integer`}},
+		{http.MethodDelete, "/schemas/s/2?permanent=true", "", http.StatusOK, []string{`2`}},
+		{http.MethodGet, "/schemas/s/versions?deleted=true", "", http.StatusOK, nil},
+		{http.MethodDelete, "/schemas/s", "", http.StatusOK, []string{`[1]`}},
+		{http.MethodGet, "/schemas/s", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas?names_only=true&deleted=true", "", http.StatusOK, []string{`"s"`}},
+		{http.MethodDelete, "/schemas/s?permanent=true", "", http.StatusOK, []string{`[1]`}},
+		{http.MethodGet, "/schemas?names_only=true&deleted=true", "", http.StatusOK, []string{`["address","order"]`}},
+
+		// Referenced versions cannot be deleted
+		{http.MethodDelete, "/schemas/address/1", "", http.StatusConflict, nil},
+		{http.MethodDelete, "/schemas/address", "", http.StatusConflict, nil},
+		{http.MethodDelete, "/schemas/order", "", http.StatusOK, []string{`[1]`}},
+		{http.MethodDelete, "/schemas/address/1", "", http.StatusOK, nil},
+		{http.MethodDelete, "/schemas/address/1?permanent=true", "", http.StatusConflict, nil},
+	})
+}
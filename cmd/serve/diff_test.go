@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodGet, "/schemas/orders/diff", "", http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders", `{"type": "object", "properties": {"note": {"type": "string", "maxLength": 10}, "kind": {"anyOf": [{"type": "string"}, {"type": "integer"}]}}}`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"note": {"type": "string", "maxLength": 5}, "kind": {"anyOf": [{"type": "string"}]}}}`, http.StatusOK, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"note": {"$ref": "#/$defs/note"}, "kind": {"anyOf": [{"type": "string"}]}}, "$defs": {"note": {"type": "string", "maxLength": 5}}}`, http.StatusOK, nil},
+		{http.MethodPut, "/config/orders", `{"compatibility": "BACKWARD"}`, http.StatusOK, nil},
+		{http.MethodGet, "/schemas/orders/diff?from=1&to=2", "", http.StatusOK, []string{
+			`"level":"BACKWARD"`, `"breaking":true`,
+			`{"kind":"SUBSCHEMA_REMOVED","path":"#/properties/kind/anyOf/1"`,
+			`{"kind":"CONSTRAINT_CHANGED","path":"#/properties/note","message":"maxLength changed from 10 to 5","from":10,"to":5,"breaking":true}`,
+		}},
+		// to defaults to the latest version and from to the one before
+		{http.MethodGet, "/schemas/orders/diff", "", http.StatusOK, []string{`"from":2`, `"to":3`, `"breaking":false`, `"DEFINITION_ADDED"`}},
+		{http.MethodGet, "/schemas/orders/diff?from=1&to=2&format=text", "", http.StatusOK, []string{
+			"--- orders v1\n+++ orders v2\n",
+			"@@ #/properties/note @@ CONSTRAINT_CHANGED BREAKING: maxLength changed from 10 to 5\n-10\n+5\n",
+		}},
+		{http.MethodGet, "/schemas/orders/diff?from=1&to=9", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/orders/diff?from=x", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas/orders/diff?to=x", "", http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/user?schemaType=AVRO", `"string"`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/user?schemaType=AVRO", `"int"`, http.StatusOK, nil},
+		{http.MethodGet, "/schemas/user/diff", "", http.StatusUnprocessableEntity, nil},
+	})
+}
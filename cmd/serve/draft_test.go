@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestDraftRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/price", `{"$schema": "http://json-schema.org/draft-04/schema#", "exclusiveMinimum": 0}`, http.StatusBadRequest, []string{"draft-04"}},
+		{http.MethodPost, "/schemas/price", `{"$schema": "http://json-schema.org/draft-03/schema#"}`, http.StatusBadRequest, []string{"unsupported $schema"}},
+		{http.MethodPost, "/schemas/price", `{"$schema": "http://json-schema.org/draft-04/schema#", "title": "Price", "type": "number", "minimum": 0, "exclusiveMinimum": true}`, http.StatusCreated, []string{`"Draft":"draft-04"`}},
+		{http.MethodGet, "/schemas/price/avro", "", http.StatusOK, []string{`"float"`}},
+		{http.MethodPost, "/schemas/tree", `{"$schema": "https://json-schema.org/draft/2020-12/schema", "$dynamicAnchor": "node", "title": "Tree", "type": "object", "properties": {"children": {"type": "array", "items": {"$dynamicRef": "#node"}}, "pair": {"type": "array", "prefixItems": [{"type": "string"}, {"type": "integer"}], "items": false}}, "required": ["children"], "additionalProperties": false}`, http.StatusCreated, []string{`"Draft":"2020-12"`}},
+		{http.MethodGet, "/schemas/tree/avro", "", http.StatusOK, []string{`"items":"Tree"`, `"items":["string","int"]`}},
+		{http.MethodPost, "/schemas/tree/validate", `{"children": [{"children": []}], "pair": ["a", 1]}`, http.StatusOK, []string{`"valid":true`}},
+		{http.MethodPost, "/schemas/tree/validate", `{"children": [{"children": [1]}]}`, http.StatusOK, []string{`"valid":false`}},
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestLookupRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	avro := `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string"}]}`
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/orders/lookup", `{"type": "string"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "integer"}}}`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/orders/lookup", `{"properties":{"id":{"type":"integer"}},"type":"object"}`, http.StatusOK, []string{`"Version":1`, `"Fingerprint":"`}},
+		{http.MethodPost, "/schemas/orders/lookup", `{"type": "object"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders/lookup", `{"type": `, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/orders/lookup?schemaType=XML", `{}`, http.StatusUnprocessableEntity, nil},
+
+		{http.MethodPost, "/schemas/r?schemaType=AVRO", avro, http.StatusCreated, []string{`"Version":1`}},
+		{http.MethodPost, "/schemas/r/lookup", `{"name":"R","type":"record","fields":[{"type":"string","name":"a"}]}`, http.StatusOK, []string{`"Version":1`}},
+		// A default changes what readers accept, so it is new content
+		{http.MethodPost, "/schemas/r/lookup", `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string", "default": ""}]}`, http.StatusNotFound, nil},
+		{http.MethodPut, "/schemas/r", `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string", "default": ""}]}`, http.StatusOK, []string{`"Version":2`}},
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+// listRoute follows the cursors of a listing on app and returns every listed
+// version as "name/version".
+func listRoute(t *testing.T, app *App, target string) []string {
+	t.Helper()
+	listed := []string{}
+	cursor := ""
+	for pages := 0; pages < 100; pages++ {
+		var page service.ListPage
+		decode(t, app, http.MethodGet, target+"&cursor="+url.QueryEscape(cursor), "", &page)
+		for _, schema := range page.Schemas {
+			listed = append(listed, fmt.Sprintf("%s/%d", schema.Name, schema.Version))
+		}
+		if page.Next == "" {
+			return listed
+		}
+		cursor = page.Next
+	}
+	t.Fatal("listing does not end")
+	return nil
+}
+
+func TestListRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/b?owner=team&tag=payments&label=tier=1", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/a?schemaType=AVRO", `"string"`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/b", `{"type": "integer"}`, http.StatusOK, nil},
+		{http.MethodPost, "/schemas/ab", `{"type": "string"}`, http.StatusCreated, nil},
+	})
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/schemas?limit=1", []string{"a/1", "ab/1", "b/1", "b/2"}},
+		{"/schemas?limit=3&order=desc", []string{"b/2", "b/1", "ab/1", "a/1"}},
+		{"/schemas?limit=2&sort=created", []string{"b/1", "a/1", "b/2", "ab/1"}},
+		{"/schemas?limit=2&sort=updated&order=desc", []string{"ab/1", "b/2", "a/1", "b/1"}},
+		{"/schemas?prefix=a", []string{"a/1", "ab/1"}},
+		{"/schemas?schemaType=AVRO", []string{"a/1"}},
+		{"/schemas?owner=team&tag=payments&label=tier=1", []string{"b/1", "b/2"}},
+		{"/schemas?tag=payments&tag=other", []string{}},
+		{"/schemas?created_after=2000-01-01T00:00:00Z&created_before=2100-01-01T00:00:00Z", []string{"a/1", "ab/1", "b/1", "b/2"}},
+		{"/schemas?updated_before=2000-01-01T00:00:00Z", []string{}},
+	}
+	for _, tt := range tests {
+		if got := listRoute(t, app, tt.target); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET %s: got %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	runSteps(t, app, []step{
+		{http.MethodGet, "/schemas?limit=0", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?sort=size", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?order=up", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?schemaType=XML", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?label=tier", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?created_after=yesterday", "", http.StatusBadRequest, nil},
+		{http.MethodGet, "/schemas?cursor=garbage", "", http.StatusBadRequest, nil},
+	})
+}
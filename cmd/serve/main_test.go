@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+// step is a request made by a route test and the answer it expects. The
+// body of the answer must contain each of want.
+type step struct {
+	method string
+	target string
+	body   string
+	status int
+	want   []string
+}
+
+// newTestApp returns an app on an empty MemoryStore with the given default
+// compatibility level.
+func newTestApp(t *testing.T, level compat.Level) *App {
+	t.Helper()
+	schemaService := service.NewSchemaService(service.NewMemoryStore())
+	schemaService.SetDefaultCompatibility(level)
+	return NewApp(schemaService)
+}
+
+func do(app *App, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// runSteps makes the requests of steps in order on app.
+func runSteps(t *testing.T, app *App, steps []step) {
+	t.Helper()
+	for _, s := range steps {
+		rec := do(app, s.method, s.target, s.body)
+		if rec.Code != s.status {
+			t.Errorf("%s %s: got %d, want %d: %s", s.method, s.target, rec.Code, s.status, rec.Body)
+			continue
+		}
+		for _, want := range s.want {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("%s %s: %s does not contain %s", s.method, s.target, rec.Body, want)
+			}
+		}
+	}
+}
+
+// decode decodes the JSON answer of a request made on app into v.
+func decode(t *testing.T, app *App, method, target, body string, v interface{}) {
+	t.Helper()
+	rec := do(app, method, target, body)
+	if rec.Code >= 300 {
+		t.Fatalf("%s %s: got %d: %s", method, target, rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s %s: %v: %s", method, target, err, rec.Body)
+	}
+}
+
+func TestSchemaRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	runSteps(t, app, []step{
+		{http.MethodGet, "/schemas/orders", "", http.StatusNotFound, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "string"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "integer"}}, "additionalProperties": false}`, http.StatusCreated, []string{`"Version":1`}},
+		{http.MethodPost, "/schemas/orders", `{"additionalProperties":false,"type":"object","properties":{"id":{"type":"integer"}}}`, http.StatusOK, []string{`"Version":1`}},
+		{http.MethodPost, "/schemas/orders", `{"type": "string"}`, http.StatusConflict, nil},
+		{http.MethodPost, "/schemas/broken", `{"type": `, http.StatusBadRequest, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "string"}}, "additionalProperties": false}`, http.StatusConflict, []string{`"TYPE_NARROWED"`, `#/properties/id`}},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "integer"}, "note": {"type": "string"}}, "additionalProperties": false}`, http.StatusOK, []string{`"Version":2`}},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "integer"}}, "additionalProperties": false}`, http.StatusOK, []string{`"Version":1`}},
+		{http.MethodGet, "/schemas/orders", "", http.StatusOK, []string{`"Version":2`, `"note"`}},
+		{http.MethodGet, "/schemas/orders/1", "", http.StatusOK, []string{`"Version":1`}},
+		{http.MethodGet, "/schemas/orders/3", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/orders/x", "", http.StatusNotFound, nil},
+	})
+}
+
+func TestSchemaRoutesMetadataFromQuery(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/orders?owner=team&tag=a", `{"type": "string"}`, http.StatusCreated, []string{`"team"`, `"a"`}},
+		// New versions keep the metadata of the latest one
+		{http.MethodPut, "/schemas/orders?tag=b", `{"type": "integer"}`, http.StatusOK, []string{`"team"`, `"b"`}},
+		{http.MethodPut, "/schemas/orders?label=x", `{"type": "number"}`, http.StatusBadRequest, nil},
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestMetadataRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPatch, "/schemas/orders/metadata", `{"owner": "team-x"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders?owner=team-x&tag=core&label=tier=1", `{"type": "string"}`, http.StatusCreated,
+			[]string{`"Metadata":{"owner":"team-x","tags":["core"],"labels":{"tier":"1"}}`}},
+		{http.MethodPost, "/schemas/users?label=tier", `{"type": "string"}`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/users?owner=team-y&tag=sales", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/orders?tag=sales&label=env=prod", `{"type": "integer"}`, http.StatusOK,
+			[]string{`"Metadata":{"owner":"team-x","tags":["sales"],"labels":{"env":"prod","tier":"1"}}`}},
+		{http.MethodPatch, "/schemas/orders/metadata", `{"contact": "x@example.com", "labels": {"tier": null}}`, http.StatusOK,
+			[]string{`"Version":2`, `"Metadata":{"owner":"team-x","contact":"x@example.com","tags":["sales"],"labels":{"env":"prod"}}`}},
+		{http.MethodPatch, "/schemas/orders/1/metadata", `{"description": "first"}`, http.StatusOK,
+			[]string{`"Version":1`, `"description":"first"`, `"contact":"x@example.com"`}},
+		{http.MethodGet, "/schemas/orders/2", "", http.StatusOK, []string{`"contact":"x@example.com"`}},
+		{http.MethodPatch, "/schemas/orders/metadata", `{"labels": {"$tier": "1"}}`, http.StatusBadRequest, nil},
+		{http.MethodPatch, "/schemas/orders/metadata", `{"owner": `, http.StatusBadRequest, nil},
+		{http.MethodPatch, "/schemas/orders/3/metadata", `{"owner": "team-y"}`, http.StatusNotFound, nil},
+		{http.MethodPatch, "/schemas/orders/x/metadata", `{"owner": "team-y"}`, http.StatusNotFound, nil},
+	})
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/schemas?owner=team-x", []string{"orders/1", "orders/2"}},
+		{"/schemas?tag=sales", []string{"orders/2", "users/1"}},
+		{"/schemas?tag=core&tag=sales", []string{}},
+		{"/schemas?label=env=prod", []string{"orders/2"}},
+		{"/schemas?owner=team-y&tag=sales", []string{"users/1"}},
+	}
+	for _, tt := range tests {
+		if got := listRoute(t, app, tt.target); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	// A name whose versions are all deleted is not patched
+	runSteps(t, app, []step{
+		{http.MethodDelete, "/schemas/users", "", http.StatusOK, nil},
+		{http.MethodPatch, "/schemas/users/metadata", `{"owner": "team-z"}`, http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas?deleted=true&owner=team-z", "", http.StatusOK, []string{`"schemas":[]`}},
+	})
+}
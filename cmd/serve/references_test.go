@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestReferenceRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/order", `{"type": "object", "properties": {"to": {"$ref": "registry:address/1"}}}`, http.StatusUnprocessableEntity, nil},
+		{http.MethodPost, "/schemas/address", `{"title": "Address", "type": "object", "properties": {"street": {"type": "string"}}, "required": ["street"]}`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/order", `{"title": "Order", "type": "object", "properties": {"to": {"$ref": "registry:address/1"}}, "required": ["to"]}`, http.StatusCreated, []string{`"References":[{"Name":"address","Version":1}]`}},
+		{http.MethodGet, "/schemas/address/1/referencedby", "", http.StatusOK, []string{`[{"Name":"order","Version":1}]`}},
+		{http.MethodGet, "/schemas/address/2/referencedby", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/address/x/referencedby", "", http.StatusNotFound, nil},
+		// The Avro schema embeds the referenced schema as a record
+		{http.MethodGet, "/schemas/order/avro", "", http.StatusOK, []string{`"name":"to","type":{"type":"record","name":"Address"`}},
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestSchemaTypeRoutes(t *testing.T) {
+	app := newTestApp(t, compat.Backward)
+	avro := `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}]}`
+	proto := `syntax = "proto3"; message User { int64 id = 1; }`
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/user?schemaType=XML", avro, http.StatusUnprocessableEntity, nil},
+		{http.MethodPost, "/schemas/user?schemaType=AVRO", `{"type": "record"}`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/user?schemaType=AVRO", avro, http.StatusCreated, []string{`"SchemaType":"AVRO"`, `"Version":1`}},
+		// Avro schemas are returned as registered
+		{http.MethodGet, "/schemas/user/avro", "", http.StatusOK, []string{avro}},
+		// PUT keeps the type of the latest version
+		{http.MethodPut, "/schemas/user", `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}, {"name": "mail", "type": "string", "default": ""}]}`, http.StatusOK, []string{`"SchemaType":"AVRO"`, `"Version":2`}},
+		{http.MethodPut, "/schemas/user", `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "string"}]}`, http.StatusConflict, nil},
+		{http.MethodPut, "/schemas/user?schemaType=JSON", `{"type": "object"}`, http.StatusConflict, nil},
+
+		{http.MethodPost, "/schemas/status?schemaType=PROTOBUF", `message {`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/status?schemaType=PROTOBUF", proto, http.StatusCreated, []string{`"SchemaType":"PROTOBUF"`}},
+		{http.MethodPost, "/schemas/status?schemaType=PROTOBUF", "syntax = \"proto3\";\n// the same\nmessage User {\n  int64 id = 1;\n}\n", http.StatusOK, []string{`"Version":1`}},
+		{http.MethodPut, "/schemas/status", `syntax = "proto3"; message User { string id = 1; }`, http.StatusConflict, nil},
+		{http.MethodGet, "/schemas/status/avro", "", http.StatusUnprocessableEntity, nil},
+	})
+}
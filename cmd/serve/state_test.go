@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+func TestStateRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/orders", `{"type": "string"}`, http.StatusCreated, []string{`"State":"active"`}},
+		{http.MethodPut, "/schemas/orders?state=draft", `{"type": "integer"}`, http.StatusOK, []string{`"Version":2`, `"State":"draft"`}},
+		{http.MethodPut, "/schemas/orders?state=deprecated", `{"type": "number"}`, http.StatusBadRequest, nil},
+		// Drafts are never the latest version
+		{http.MethodGet, "/schemas/orders", "", http.StatusOK, []string{`"Version":1`}},
+		{http.MethodGet, "/subjects/orders/versions/latest", "", http.StatusOK, []string{`"version":1`}},
+		{http.MethodPost, "/schemas/orders/2/state", `{"state": "deprecated"}`, http.StatusConflict, nil},
+		{http.MethodPost, "/schemas/orders/2/state", `{"state": "ACTIVE"}`, http.StatusOK, []string{`"State":"active"`}},
+		{http.MethodGet, "/schemas/orders", "", http.StatusOK, []string{`"Version":2`}},
+		{http.MethodPost, "/schemas/orders/2/state", `{"state": "draft"}`, http.StatusConflict, nil},
+		{http.MethodPost, "/schemas/orders/2/state", `{"state": "retired"}`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/orders/2/state", `{}`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/orders/2/state", `{"state": `, http.StatusBadRequest, nil},
+		{http.MethodPost, "/schemas/orders/9/state", `{"state": "active"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders/x/state", `{"state": "active"}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders/1/state", `{"state": "active", "sunset": "2030-01-01T00:00:00Z"}`, http.StatusConflict, nil},
+		{http.MethodPost, "/schemas/orders/1/state", `{"state": "deprecated", "sunset": "2030-01-01T00:00:00Z", "replacedBy": {"Version": 2}}`, http.StatusOK,
+			[]string{`"State":"deprecated"`, `"Sunset":"2030-01-01T00:00:00Z"`}},
+	})
+
+	var id struct{ ID int }
+	decode(t, app, http.MethodGet, "/subjects/orders/versions/1", "", &id)
+	idTarget := "/schemas/ids/" + strconv.Itoa(id.ID)
+
+	// Deprecated versions are served with the deprecation headers, wherever
+	// they are read from
+	for _, target := range []string{"/schemas/orders/1", "/schemas/orders/avro?version=1", "/subjects/orders/versions/1", idTarget} {
+		rec := do(app, http.MethodGet, target, "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: got %d, want 200: %s", target, rec.Code, rec.Body)
+			continue
+		}
+		header := rec.Header()
+		if header.Get("Deprecation") == "" || header.Get("Deprecation") == "true" {
+			t.Errorf("GET %s: got Deprecation %q, want a date", target, header.Get("Deprecation"))
+		}
+		if got, want := header.Get("Sunset"), "Tue, 01 Jan 2030 00:00:00 GMT"; got != want {
+			t.Errorf("GET %s: got Sunset %q, want %q", target, got, want)
+		}
+		if got, want := header.Get("Link"), `</schemas/orders/2>; rel="successor-version"`; got != want {
+			t.Errorf("GET %s: got Link %q, want %q", target, got, want)
+		}
+	}
+
+	// Disabled versions are gone, unless the same schema id is served from
+	// another version
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/orders/1/state", `{"state": "disabled"}`, http.StatusOK, []string{`"State":"disabled"`}},
+		{http.MethodGet, "/schemas/orders/1", "", http.StatusGone, nil},
+		{http.MethodGet, "/schemas/orders/avro?version=1", "", http.StatusGone, nil},
+		{http.MethodGet, "/subjects/orders/versions/1", "", http.StatusGone, nil},
+		{http.MethodGet, idTarget, "", http.StatusGone, []string{`"error_code":40403`}},
+		{http.MethodPost, "/schemas/copy", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodGet, idTarget, "", http.StatusOK, []string{`"schema":"{\"type\":\"string\"}"`}},
+		{http.MethodPost, "/schemas/orders/1/state", `{"state": "active"}`, http.StatusOK, nil},
+		{http.MethodGet, "/schemas/orders/1", "", http.StatusOK, nil},
+	})
+
+	var versions []service.VersionInfo
+	decode(t, app, http.MethodGet, "/schemas/orders/versions", "", &versions)
+	if len(versions) != 2 {
+		t.Errorf("got versions %+v, want 2", versions)
+	}
+}
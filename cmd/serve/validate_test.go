@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestValidateRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodPost, "/schemas/orders/validate", `{}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "integer"}}, "required": ["id"]}`, http.StatusCreated, nil},
+		{http.MethodPut, "/schemas/orders", `{"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]}`, http.StatusOK, nil},
+		{http.MethodPost, "/schemas/orders/validate", `{"id": "a"}`, http.StatusOK, []string{`"version":2`, `"valid":true`}},
+		{http.MethodPost, "/schemas/orders/validate", `{"id": 1}`, http.StatusOK, []string{`"valid":false`, `"pointer":"/id"`}},
+		{http.MethodPost, "/schemas/orders/1/validate", `{"id": 1}`, http.StatusOK, []string{`"version":1`, `"valid":true`}},
+		{http.MethodPost, "/schemas/orders/1/validate", `{}`, http.StatusOK, []string{`"valid":false`}},
+		{http.MethodPost, "/schemas/orders/3/validate", `{}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders/x/validate", `{}`, http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/orders/validate", ` `, http.StatusBadRequest, nil},
+
+		{http.MethodPost, "/schemas/user?schemaType=AVRO", `"string"`, http.StatusCreated, nil},
+		{http.MethodPost, "/schemas/user/validate", `"a"`, http.StatusUnprocessableEntity, nil},
+	})
+
+	ndjson := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/schemas/orders/validate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		app.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := ndjson("{\"id\": \"a\"}\n\n{\"id\": 1}\n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch: got %d: %s", rec.Code, rec.Body)
+	}
+	for _, want := range []string{`"valid":false`, `{"index":0,"valid":true`, `{"index":1,"valid":false`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("batch: %s does not contain %s", rec.Body, want)
+		}
+	}
+	if rec := ndjson("\n\n"); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: got %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+	"github.com/tradeface/schema-registry/internal/service"
+)
+
+func TestVersionRoutes(t *testing.T) {
+	app := newTestApp(t, compat.None)
+	runSteps(t, app, []step{
+		{http.MethodGet, "/schemas?names_only=true", "", http.StatusOK, []string{`[]`}},
+		{http.MethodGet, "/schemas/b/versions", "", http.StatusNotFound, nil},
+		{http.MethodPost, "/schemas/b", `{"type": "string"}`, http.StatusCreated, nil},
+	})
+	time.Sleep(time.Millisecond)
+	runSteps(t, app, []step{
+		{http.MethodPut, "/schemas/b", `{"type": "integer"}`, http.StatusOK, nil},
+		{http.MethodPost, "/schemas/a", `{"type": "string"}`, http.StatusCreated, nil},
+		{http.MethodGet, "/schemas?names_only=true", "", http.StatusOK, []string{`["a","b"]`}},
+		{http.MethodGet, "/schemas/b/1", "", http.StatusOK, []string{`"Version":1`, `"string"`}},
+		{http.MethodGet, "/schemas/b/2", "", http.StatusOK, []string{`"Version":2`, `"integer"`}},
+		{http.MethodGet, "/schemas/b/3", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/b/x", "", http.StatusNotFound, nil},
+		{http.MethodGet, "/schemas/b", "", http.StatusOK, []string{`"Version":2`}},
+	})
+
+	var versions []service.VersionInfo
+	decode(t, app, http.MethodGet, "/schemas/b/versions", "", &versions)
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Fatalf("got versions %+v, want 1 and 2", versions)
+	}
+	// Every version reports its own creation time
+	if !versions[1].CreatedAt.After(versions[0].CreatedAt) {
+		t.Errorf("version 2 created at %v, not after version 1 at %v", versions[1].CreatedAt, versions[0].CreatedAt)
+	}
+}
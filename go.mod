@@ -3,9 +3,10 @@ module github.com/tradeface/schema-registry
 go 1.17
 
 require (
-	github.com/actgardner/gogen-avro/v10 v10.2.1
+	github.com/emicklei/proto v1.14.2
 	github.com/labstack/echo/v4 v4.10.2
 	github.com/linkedin/goavro/v2 v2.11.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/xeipuuv/gojsonschema v1.2.0
 	go.mongodb.org/mongo-driver v1.11.2
 )
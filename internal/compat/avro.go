@@ -0,0 +1,360 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Avro violation types, named after the schema resolution rule of the Avro
+// specification that fails.
+const (
+	AvroTypeMismatch        = "TYPE_MISMATCH"
+	AvroNameMismatch        = "NAME_MISMATCH"
+	AvroFixedSizeMismatch   = "FIXED_SIZE_MISMATCH"
+	AvroMissingEnumSymbols  = "MISSING_ENUM_SYMBOLS"
+	AvroMissingUnionBranch  = "MISSING_UNION_BRANCH"
+	AvroFieldMissingDefault = "READER_FIELD_MISSING_DEFAULT_VALUE"
+)
+
+const (
+	avroUnion  = "union"
+	avroRecord = "record"
+	avroEnum   = "enum"
+	avroFixed  = "fixed"
+	avroArray  = "array"
+	avroMap    = "map"
+)
+
+// avroSchema is a parsed Avro schema. References to named types point to the
+// same avroSchema as their definition.
+type avroSchema struct {
+	Type string
+	// Name is the full name of records, enums and fixed types
+	Name    string
+	Aliases []string
+	Fields  []avroField
+	Symbols []string
+	// HasDefault is set for enums with a default symbol
+	HasDefault bool
+	Size       int
+	Items      *avroSchema
+	Values     *avroSchema
+	Branches   []*avroSchema
+}
+
+type avroField struct {
+	Name       string
+	Aliases    []string
+	Type       *avroSchema
+	HasDefault bool
+}
+
+// avroPromotions lists the writer types each reader type can be promoted from.
+var avroPromotions = map[string][]string{
+	"long":   {"int"},
+	"float":  {"int", "long"},
+	"double": {"int", "long", "float"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// CheckAvro compares a candidate Avro schema against the previous versions of
+// the same schema, ordered from oldest to newest, following the schema
+// resolution rules of the Avro specification.
+func CheckAvro(level Level, candidate string, previous []string) ([]Violation, error) {
+	candidateSchema, err := parseAvro(candidate)
+	if err != nil {
+		return nil, err
+	}
+	previousSchemas := make([]*avroSchema, 0, len(previous))
+	for _, p := range previous {
+		schema, err := parseAvro(p)
+		if err != nil {
+			return nil, err
+		}
+		previousSchemas = append(previousSchemas, schema)
+	}
+
+	return checkVersions(level, len(previousSchemas), func(backward bool, i int) []Violation {
+		c := &avroChecker{backward: backward, visiting: map[string]bool{}}
+		if backward {
+			c.compare(candidateSchema, previousSchemas[i], "#")
+		} else {
+			c.compare(previousSchemas[i], candidateSchema, "#")
+		}
+		return c.violations
+	}), nil
+}
+
+// avroChecker collects the violations found while resolving data written with
+// a writer schema against a reader schema.
+type avroChecker struct {
+	backward bool
+	// visiting holds the pairs of records being compared, to stop on
+	// recursive schemas
+	visiting   map[string]bool
+	violations []Violation
+}
+
+func (c *avroChecker) report(typ, path, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{
+		Type:    typ,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// reader names the version acting as the reader in messages.
+func (c *avroChecker) reader() string {
+	if c.backward {
+		return "the new version"
+	}
+	return "the previous version"
+}
+
+// compare reports every way in which data written with writer cannot be read
+// with reader.
+func (c *avroChecker) compare(reader, writer *avroSchema, path string) {
+	if writer.Type == avroUnion {
+		for i, branch := range writer.Branches {
+			c.compare(reader, branch, fmt.Sprintf("%s/%d", path, i))
+		}
+		return
+	}
+	if reader.Type == avroUnion {
+		branch := matchBranch(reader.Branches, writer)
+		if branch == nil {
+			c.report(AvroMissingUnionBranch, path, "%s has no union branch for %s", c.reader(), writer)
+			return
+		}
+		c.compare(branch, writer, path)
+		return
+	}
+
+	if reader.Type != writer.Type {
+		if !promotable(reader, writer) {
+			c.report(AvroTypeMismatch, path, "%s reads %s as %s", c.reader(), writer, reader)
+		}
+		return
+	}
+	if reader.Name != "" && !avroNamesMatch(reader, writer) {
+		c.report(AvroNameMismatch, path, "%s reads %s as %s", c.reader(), writer.Name, reader.Name)
+		return
+	}
+
+	switch reader.Type {
+	case avroRecord:
+		key := reader.Name + "|" + writer.Name
+		if c.visiting[key] {
+			return
+		}
+		c.visiting[key] = true
+		defer delete(c.visiting, key)
+		for _, field := range reader.Fields {
+			fieldPath := path + "/" + field.Name
+			if writerField := findAvroField(writer, field); writerField != nil {
+				c.compare(field.Type, writerField.Type, fieldPath)
+			} else if !field.HasDefault {
+				c.report(AvroFieldMissingDefault, fieldPath, "field %s of %s has no default and is missing in the data", field.Name, c.reader())
+			}
+		}
+	case avroEnum:
+		if reader.HasDefault {
+			return
+		}
+		missing := []string{}
+		for _, symbol := range writer.Symbols {
+			if !containsString(reader.Symbols, symbol) {
+				missing = append(missing, symbol)
+			}
+		}
+		if len(missing) > 0 {
+			c.report(AvroMissingEnumSymbols, path, "%s lacks the symbols %s of enum %s", c.reader(), strings.Join(missing, ", "), reader.Name)
+		}
+	case avroFixed:
+		if reader.Size != writer.Size {
+			c.report(AvroFixedSizeMismatch, path, "%s reads fixed %s of size %d as size %d", c.reader(), writer.Name, writer.Size, reader.Size)
+		}
+	case avroArray:
+		c.compare(reader.Items, writer.Items, path+"/items")
+	case avroMap:
+		c.compare(reader.Values, writer.Values, path+"/values")
+	}
+}
+
+func (s *avroSchema) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+// matchBranch returns the reader union branch that data of writer resolves
+// to: the first branch of the same type and name, else the first branch
+// writer can be promoted to.
+func matchBranch(branches []*avroSchema, writer *avroSchema) *avroSchema {
+	for _, branch := range branches {
+		if branch.Type == writer.Type && (branch.Name == "" || avroNamesMatch(branch, writer)) {
+			return branch
+		}
+	}
+	for _, branch := range branches {
+		if promotable(branch, writer) {
+			return branch
+		}
+	}
+	return nil
+}
+
+func promotable(reader, writer *avroSchema) bool {
+	return containsString(avroPromotions[reader.Type], writer.Type)
+}
+
+// avroNamesMatch reports whether the named types have the same name or the
+// reader declares the writer name as an alias. Unqualified names match too.
+func avroNamesMatch(reader, writer *avroSchema) bool {
+	for _, name := range append([]string{reader.Name}, reader.Aliases...) {
+		if name == writer.Name || unqualified(name) == unqualified(writer.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func findAvroField(record *avroSchema, field avroField) *avroField {
+	for _, name := range append([]string{field.Name}, field.Aliases...) {
+		for i := range record.Fields {
+			if record.Fields[i].Name == name {
+				return &record.Fields[i]
+			}
+		}
+	}
+	return nil
+}
+
+func unqualified(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAvro parses the JSON form of an Avro schema.
+func parseAvro(schemaJSON string) (*avroSchema, error) {
+	var node interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &node); err != nil {
+		return nil, err
+	}
+	p := &avroParser{names: map[string]*avroSchema{}}
+	schema := p.parse(node, "")
+	if p.err != nil {
+		return nil, p.err
+	}
+	return schema, nil
+}
+
+type avroParser struct {
+	names map[string]*avroSchema
+	err   error
+}
+
+func (p *avroParser) parse(node interface{}, namespace string) *avroSchema {
+	switch node := node.(type) {
+	case string:
+		if named, ok := p.names[avroFullName(node, namespace)]; ok {
+			return named
+		}
+		if named, ok := p.names[node]; ok {
+			return named
+		}
+		return &avroSchema{Type: node}
+	case []interface{}:
+		union := &avroSchema{Type: avroUnion}
+		for _, branch := range node {
+			union.Branches = append(union.Branches, p.parse(branch, namespace))
+		}
+		return union
+	case map[string]interface{}:
+		t, ok := node["type"].(string)
+		if !ok {
+			return p.parse(node["type"], namespace)
+		}
+		schema := &avroSchema{Type: t}
+		switch t {
+		case avroRecord, "error", avroEnum, avroFixed:
+			if t == "error" {
+				schema.Type = avroRecord
+			}
+			name, _ := node["name"].(string)
+			if ns, ok := node["namespace"].(string); ok && !strings.Contains(name, ".") {
+				namespace = ns
+			}
+			schema.Name = avroFullName(name, namespace)
+			namespace = ""
+			if i := strings.LastIndex(schema.Name, "."); i >= 0 {
+				namespace = schema.Name[:i]
+			}
+			for _, alias := range stringList(node["aliases"]) {
+				schema.Aliases = append(schema.Aliases, avroFullName(alias, namespace))
+			}
+			// Register before parsing the fields, records may refer to themselves
+			p.names[schema.Name] = schema
+		}
+		switch schema.Type {
+		case avroRecord:
+			fields, _ := node["fields"].([]interface{})
+			for _, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := field["name"].(string)
+				_, hasDefault := field["default"]
+				schema.Fields = append(schema.Fields, avroField{
+					Name:       name,
+					Aliases:    stringList(field["aliases"]),
+					Type:       p.parse(field["type"], namespace),
+					HasDefault: hasDefault,
+				})
+			}
+		case avroEnum:
+			schema.Symbols = stringList(node["symbols"])
+			_, schema.HasDefault = node["default"]
+		case avroFixed:
+			size, _ := node["size"].(float64)
+			schema.Size = int(size)
+		case avroArray:
+			schema.Items = p.parse(node["items"], namespace)
+		case avroMap:
+			schema.Values = p.parse(node["values"], namespace)
+		}
+		return schema
+	}
+	p.err = fmt.Errorf("invalid avro schema: unexpected %v", node)
+	return &avroSchema{}
+}
+
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,121 @@
+package compat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckAvro(t *testing.T) {
+	record := func(fields string) string {
+		return `{"type": "record", "name": "R", "fields": [` + fields + `]}`
+	}
+	tests := []struct {
+		name      string
+		level     Level
+		previous  []string
+		candidate string
+		want      []string
+	}{
+		{
+			name:      "field with default added",
+			level:     Backward,
+			previous:  []string{record(`{"name": "a", "type": "string"}`)},
+			candidate: record(`{"name": "a", "type": "string"}, {"name": "b", "type": "int", "default": 0}`),
+			want:      []string{},
+		},
+		{
+			name:      "field without default added",
+			level:     Backward,
+			previous:  []string{record(`{"name": "a", "type": "string"}`)},
+			candidate: record(`{"name": "a", "type": "string"}, {"name": "b", "type": "int"}`),
+			want:      []string{AvroFieldMissingDefault},
+		},
+		{
+			name:      "field removed forward",
+			level:     Forward,
+			previous:  []string{record(`{"name": "a", "type": "string"}, {"name": "b", "type": "int"}`)},
+			candidate: record(`{"name": "a", "type": "string"}`),
+			want:      []string{AvroFieldMissingDefault},
+		},
+		{
+			name:      "int promoted to long",
+			level:     Backward,
+			previous:  []string{record(`{"name": "a", "type": "int"}`)},
+			candidate: record(`{"name": "a", "type": "long"}`),
+			want:      []string{},
+		},
+		{
+			name:      "long narrowed to int",
+			level:     Backward,
+			previous:  []string{record(`{"name": "a", "type": "long"}`)},
+			candidate: record(`{"name": "a", "type": "int"}`),
+			want:      []string{AvroTypeMismatch},
+		},
+		{
+			name:      "enum symbol removed",
+			level:     Backward,
+			previous:  []string{`{"type": "enum", "name": "E", "symbols": ["A", "B"]}`},
+			candidate: `{"type": "enum", "name": "E", "symbols": ["A"]}`,
+			want:      []string{AvroMissingEnumSymbols},
+		},
+		{
+			name:      "enum symbol removed with default",
+			level:     Backward,
+			previous:  []string{`{"type": "enum", "name": "E", "symbols": ["A", "B"]}`},
+			candidate: `{"type": "enum", "name": "E", "symbols": ["A"], "default": "A"}`,
+			want:      []string{},
+		},
+		{
+			name:      "record renamed",
+			level:     Backward,
+			previous:  []string{`{"type": "record", "name": "R", "fields": []}`},
+			candidate: `{"type": "record", "name": "S", "fields": []}`,
+			want:      []string{AvroNameMismatch},
+		},
+		{
+			name:      "record renamed with alias",
+			level:     Backward,
+			previous:  []string{`{"type": "record", "name": "R", "fields": []}`},
+			candidate: `{"type": "record", "name": "S", "aliases": ["R"], "fields": []}`,
+			want:      []string{},
+		},
+		{
+			name:      "fixed size changed",
+			level:     Backward,
+			previous:  []string{`{"type": "fixed", "name": "F", "size": 4}`},
+			candidate: `{"type": "fixed", "name": "F", "size": 8}`,
+			want:      []string{AvroFixedSizeMismatch},
+		},
+		{
+			name:      "union branch removed",
+			level:     Backward,
+			previous:  []string{record(`{"name": "a", "type": ["null", "string", "int"]}`)},
+			candidate: record(`{"name": "a", "type": ["null", "string"]}`),
+			want:      []string{AvroMissingUnionBranch},
+		},
+		{
+			name:      "none",
+			level:     None,
+			previous:  []string{`"string"`},
+			candidate: `"int"`,
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := CheckAvro(tt.level, tt.candidate, tt.previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := violationTypes(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v: %v", got, tt.want, violations)
+			}
+		})
+	}
+}
+
+func TestCheckAvroInvalid(t *testing.T) {
+	if _, err := CheckAvro(Backward, `{"type": `, []string{`"string"`}); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+}
@@ -0,0 +1,109 @@
+package compat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is a compatibility level as understood by Confluent-style registries.
+type Level string
+
+const (
+	None               Level = "NONE"
+	Backward           Level = "BACKWARD"
+	BackwardTransitive Level = "BACKWARD_TRANSITIVE"
+	Forward            Level = "FORWARD"
+	ForwardTransitive  Level = "FORWARD_TRANSITIVE"
+	Full               Level = "FULL"
+	FullTransitive     Level = "FULL_TRANSITIVE"
+)
+
+var levels = []Level{None, Backward, BackwardTransitive, Forward, ForwardTransitive, Full, FullTransitive}
+
+// ParseLevel parses a level name, ignoring case.
+func ParseLevel(s string) (Level, error) {
+	for _, level := range levels {
+		if strings.EqualFold(s, string(level)) {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("invalid compatibility level: %s", s)
+}
+
+// Backward reports whether the new schema must be able to read data written
+// with the previous one.
+func (l Level) Backward() bool {
+	return l == Backward || l == BackwardTransitive || l == Full || l == FullTransitive
+}
+
+// Forward reports whether the previous schema must be able to read data
+// written with the new one.
+func (l Level) Forward() bool {
+	return l == Forward || l == ForwardTransitive || l == Full || l == FullTransitive
+}
+
+// Transitive reports whether the new schema is checked against all previous
+// versions instead of only the latest one.
+func (l Level) Transitive() bool {
+	return l == BackwardTransitive || l == ForwardTransitive || l == FullTransitive
+}
+
+// Violation describes a single incompatible change.
+type Violation struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s at %s: %s", v.Type, v.Path, v.Message)
+}
+
+// Check compares a candidate JSON Schema against the previous versions of the
+// same schema, ordered from oldest to newest, and returns every violation of
+// the given level. An empty result means the candidate is compatible.
+func Check(level Level, candidate map[string]interface{}, previous []map[string]interface{}) []Violation {
+	return checkVersions(level, len(previous), func(backward bool, i int) []Violation {
+		if backward {
+			c := newChecker(true, candidate, previous[i])
+			c.compare(candidate, previous[i], "#")
+			return c.violations
+		}
+		c := newChecker(false, previous[i], candidate)
+		c.compare(previous[i], candidate, "#")
+		return c.violations
+	})
+}
+
+// checkVersions runs compare against the previous versions selected by level,
+// newest first, and returns the violations found without duplicates. compare
+// is called with backward set when the candidate is the reader and i indexing
+// the previous version.
+func checkVersions(level Level, n int, compare func(backward bool, i int) []Violation) []Violation {
+	if level == None || n == 0 {
+		return nil
+	}
+	oldest := 0
+	if !level.Transitive() {
+		oldest = n - 1
+	}
+
+	violations := []Violation{}
+	seen := map[Violation]bool{}
+	for i := n - 1; i >= oldest; i-- {
+		var found []Violation
+		if level.Backward() {
+			found = append(found, compare(true, i)...)
+		}
+		if level.Forward() {
+			found = append(found, compare(false, i)...)
+		}
+		for _, v := range found {
+			if !seen[v] {
+				seen[v] = true
+				violations = append(violations, v)
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,303 @@
+package compat
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// violationTypes returns the sorted distinct types of violations.
+func violationTypes(violations []Violation) []string {
+	seen := map[string]bool{}
+	types := []string{}
+	for _, v := range violations {
+		if !seen[v.Type] {
+			seen[v.Type] = true
+			types = append(types, v.Type)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     Level
+		previous  []string
+		candidate string
+		want      []string
+	}{
+		{
+			name:      "optional property added to open model",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`,
+			want:      []string{PropertyAdded},
+		},
+		{
+			name:      "unconstrained property added to open model",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"description": "anything"}}}`,
+			want:      []string{},
+		},
+		{
+			name:      "optional property added to closed model",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}, "additionalProperties": false}`,
+			want:      []string{},
+		},
+		{
+			name:      "property added under additionalProperties",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "additionalProperties": {"type": "string"}}`},
+			candidate: `{"type": "object", "properties": {"b": {"type": "integer"}}, "additionalProperties": {"type": "string"}}`,
+			want:      []string{TypeNarrowed},
+		},
+		{
+			name:      "property removed from open model backward",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			want:      []string{},
+		},
+		{
+			name:      "property removed from open model forward",
+			level:     Forward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			want:      []string{PropertyRemoved},
+		},
+		{
+			name:      "required property added",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}, "required": ["b"]}`,
+			want:      []string{RequiredPropertyAdded},
+		},
+		{
+			name:      "required property removed",
+			level:     Forward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			want:      []string{RequiredPropertyRemoved},
+		},
+		{
+			name:      "type changed",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "integer"}}}`,
+			want:      []string{TypeNarrowed},
+		},
+		{
+			name:      "type widened backward",
+			level:     Backward,
+			previous:  []string{`{"type": "integer"}`},
+			candidate: `{"type": "number"}`,
+			want:      []string{},
+		},
+		{
+			name:      "type widened forward",
+			level:     Forward,
+			previous:  []string{`{"type": "integer"}`},
+			candidate: `{"type": "number"}`,
+			want:      []string{TypeWidened},
+		},
+		{
+			name:      "enum value removed",
+			level:     Backward,
+			previous:  []string{`{"enum": ["a", "b"]}`},
+			candidate: `{"enum": ["a"]}`,
+			want:      []string{EnumNarrowed},
+		},
+		{
+			name:      "enum value added",
+			level:     Backward,
+			previous:  []string{`{"enum": ["a"]}`},
+			candidate: `{"enum": ["a", "b"]}`,
+			want:      []string{},
+		},
+		{
+			name:      "constraint tightened",
+			level:     Backward,
+			previous:  []string{`{"type": "string", "maxLength": 10}`},
+			candidate: `{"type": "string", "maxLength": 5}`,
+			want:      []string{ConstraintTightened},
+		},
+		{
+			name:      "constraint loosened full",
+			level:     Full,
+			previous:  []string{`{"type": "string", "maxLength": 5}`},
+			candidate: `{"type": "string", "maxLength": 10}`,
+			want:      []string{ConstraintLoosened},
+		},
+		{
+			name:      "const added",
+			level:     Backward,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"type": "string", "const": "a"}`,
+			want:      []string{ConstraintTightened},
+		},
+		{
+			name:      "const replaces single enum value",
+			level:     Full,
+			previous:  []string{`{"enum": ["a"]}`},
+			candidate: `{"const": "a"}`,
+			want:      []string{},
+		},
+		{
+			name:      "multipleOf tightened",
+			level:     Backward,
+			previous:  []string{`{"type": "number", "multipleOf": 2}`},
+			candidate: `{"type": "number", "multipleOf": 3}`,
+			want:      []string{ConstraintTightened},
+		},
+		{
+			name:      "multipleOf loosened backward",
+			level:     Backward,
+			previous:  []string{`{"type": "number", "multipleOf": 4}`},
+			candidate: `{"type": "number", "multipleOf": 2}`,
+			want:      []string{},
+		},
+		{
+			name:      "uniqueItems added",
+			level:     Backward,
+			previous:  []string{`{"type": "array"}`},
+			candidate: `{"type": "array", "uniqueItems": true}`,
+			want:      []string{ConstraintTightened},
+		},
+		{
+			name:      "format added",
+			level:     Backward,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"type": "string", "format": "email"}`,
+			want:      []string{ConstraintTightened},
+		},
+		{
+			name:      "pattern added",
+			level:     Backward,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"type": "string", "pattern": "^a"}`,
+			want:      []string{PatternChanged},
+		},
+		{
+			name:      "pattern removed backward",
+			level:     Backward,
+			previous:  []string{`{"type": "string", "pattern": "^a"}`},
+			candidate: `{"type": "string"}`,
+			want:      []string{},
+		},
+		{
+			name:      "anyOf narrowed",
+			level:     Backward,
+			previous:  []string{`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`},
+			candidate: `{"anyOf": [{"type": "string"}]}`,
+			want:      []string{CompositionNarrowed},
+		},
+		{
+			name:      "anyOf widened backward",
+			level:     Backward,
+			previous:  []string{`{"anyOf": [{"type": "string"}]}`},
+			candidate: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`,
+			want:      []string{},
+		},
+		{
+			name:      "anyOf widened forward",
+			level:     Forward,
+			previous:  []string{`{"anyOf": [{"type": "string"}]}`},
+			candidate: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`,
+			want:      []string{CompositionWidened},
+		},
+		{
+			name:      "oneOf replaced by a branch",
+			level:     Backward,
+			previous:  []string{`{"oneOf": [{"type": "string"}, {"type": "integer"}]}`},
+			candidate: `{"type": "string"}`,
+			want:      []string{TypeNarrowed},
+		},
+		{
+			name:      "type replaced by oneOf",
+			level:     Backward,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+			want:      []string{},
+		},
+		{
+			name:      "allOf branch changed",
+			level:     Backward,
+			previous:  []string{`{"allOf": [{"properties": {"a": {"type": "string"}}}, {"required": ["a"]}]}`},
+			candidate: `{"allOf": [{"properties": {"a": {"type": "integer"}}}, {"required": ["a"]}]}`,
+			want:      []string{TypeNarrowed},
+		},
+		{
+			name:      "allOf split",
+			level:     Full,
+			previous:  []string{`{"properties": {"a": {"type": "string"}}, "required": ["a"]}`},
+			candidate: `{"allOf": [{"properties": {"a": {"type": "string"}}}, {"required": ["a"]}]}`,
+			want:      []string{},
+		},
+		{
+			name:      "not added",
+			level:     Backward,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"type": "string", "not": {"const": "x"}}`,
+			want:      []string{CompositionNarrowed},
+		},
+		{
+			name:      "not narrowed",
+			level:     Full,
+			previous:  []string{`{"type": "string", "not": {"enum": ["x", "y"]}}`},
+			candidate: `{"type": "string", "not": {"const": "x"}}`,
+			want:      []string{CompositionWidened},
+		},
+		{
+			name:      "additional properties closed",
+			level:     Backward,
+			previous:  []string{`{"type": "object", "properties": {"a": {"type": "string"}}}`},
+			candidate: `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			want:      []string{AdditionalPropertiesNarrowed},
+		},
+		{
+			name:      "change behind $ref",
+			level:     Backward,
+			previous:  []string{`{"properties": {"a": {"$ref": "#/definitions/A"}}, "definitions": {"A": {"type": "string"}}}`},
+			candidate: `{"properties": {"a": {"$ref": "#/definitions/A"}}, "definitions": {"A": {"type": "integer"}}}`,
+			want:      []string{TypeNarrowed},
+		},
+		{
+			name:      "none",
+			level:     None,
+			previous:  []string{`{"type": "string"}`},
+			candidate: `{"type": "integer"}`,
+			want:      []string{},
+		},
+		{
+			name:      "only latest version",
+			level:     Forward,
+			previous:  []string{`{"type": "integer"}`, `{"type": "number"}`},
+			candidate: `{"type": "number"}`,
+			want:      []string{},
+		},
+		{
+			name:      "transitive",
+			level:     ForwardTransitive,
+			previous:  []string{`{"type": "integer"}`, `{"type": "number"}`},
+			candidate: `{"type": "number"}`,
+			want:      []string{TypeWidened},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := make([]map[string]interface{}, 0, len(tt.previous))
+			for _, p := range tt.previous {
+				previous = append(previous, mustSchema(t, p))
+			}
+			violations := Check(tt.level, mustSchema(t, tt.candidate), previous)
+			if got := violationTypes(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v: %v", got, tt.want, violations)
+			}
+		})
+	}
+}
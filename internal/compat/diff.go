@@ -0,0 +1,485 @@
+package compat
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change kinds reported by Diff, besides PropertyAdded and PropertyRemoved.
+const (
+	TypeChanged                 = "TYPE_CHANGED"
+	RefChanged                  = "REF_CHANGED"
+	RequiredAdded               = "REQUIRED_ADDED"
+	RequiredRemoved             = "REQUIRED_REMOVED"
+	EnumAdded                   = "ENUM_ADDED"
+	EnumRemoved                 = "ENUM_REMOVED"
+	EnumValuesAdded             = "ENUM_VALUES_ADDED"
+	EnumValuesRemoved           = "ENUM_VALUES_REMOVED"
+	ConstraintChanged           = "CONSTRAINT_CHANGED"
+	AdditionalPropertiesChanged = "ADDITIONAL_PROPERTIES_CHANGED"
+	DefinitionAdded             = "DEFINITION_ADDED"
+	DefinitionRemoved           = "DEFINITION_REMOVED"
+	AnnotationChanged           = "ANNOTATION_CHANGED"
+	// SubschemaAdded, SubschemaRemoved and SubschemaChanged are reported for
+	// the subschemas of anyOf, oneOf, not, if, then, else, patternProperties
+	// and the items of arrays and tuples
+	SubschemaAdded   = "SUBSCHEMA_ADDED"
+	SubschemaRemoved = "SUBSCHEMA_REMOVED"
+	SubschemaChanged = "SUBSCHEMA_CHANGED"
+)
+
+// constraints are the keywords compared by Diff as CONSTRAINT_CHANGED, in
+// the order they are reported.
+var constraints = append(append(append([]string{}, lowerBounds...), upperBounds...),
+	"pattern", "format", "multipleOf", "const", "uniqueItems")
+
+// annotations are the keywords compared by Diff as ANNOTATION_CHANGED.
+var annotations = []string{"title", "description", "default", "deprecated"}
+
+// breakingViolations maps a change kind to the violation types that make it
+// breaking when reported at the path of the change.
+var breakingViolations = map[string][]string{
+	TypeChanged:                 {TypeNarrowed, TypeWidened},
+	RequiredAdded:               {RequiredPropertyAdded},
+	RequiredRemoved:             {RequiredPropertyRemoved, PropertyRemoved},
+	EnumAdded:                   {EnumNarrowed},
+	EnumRemoved:                 {EnumWidened},
+	EnumValuesAdded:             {EnumWidened},
+	EnumValuesRemoved:           {EnumNarrowed},
+	ConstraintChanged:           {ConstraintTightened, ConstraintLoosened, PatternChanged},
+	AdditionalPropertiesChanged: {AdditionalPropertiesNarrowed, AdditionalPropertiesWidened},
+	PropertyAdded:               {PropertyAdded},
+	PropertyRemoved:             {PropertyRemoved},
+}
+
+// Change is a difference between two versions of a JSON Schema. From and To
+// hold the values before and after the change, nil when absent.
+type Change struct {
+	Kind     string      `json:"kind"`
+	Path     string      `json:"path"`
+	Message  string      `json:"message"`
+	From     interface{} `json:"from,omitempty"`
+	To       interface{} `json:"to,omitempty"`
+	Breaking bool        `json:"breaking"`
+}
+
+// Diff lists the changes from one version of a JSON Schema to another and
+// the violations of level the second version has against the first. A change
+// is breaking when a violation is reported for it; property additions and
+// removals, $ref changes and subschema changes also when any violation is
+// reported within, and any change within a branch of anyOf, oneOf or not
+// that a violation is reported for. Where both versions use the same $ref,
+// violations are reported where it is used while changes are reported where
+// the referenced schema is defined, so violations are matched at the path
+// they reach through the $refs of either version too. Where the $refs differ,
+// or only one version uses one, the schemas they resolve to are compared in
+// place, and allOf is merged into the schema holding it, as Check does.
+func Diff(level Level, from, to map[string]interface{}) ([]Change, []Violation) {
+	d := &differ{fromRoot: from, toRoot: to, visiting: map[string]bool{}}
+	d.diff(from, to, "#")
+
+	violations := Check(level, to, []map[string]interface{}{from})
+	for i := range d.changes {
+		change := &d.changes[i]
+		for _, v := range violations {
+			paths := []string{v.Path, targetPath(from, v.Path), targetPath(to, v.Path)}
+			for _, path := range paths {
+				if change.breakingAt(v.Type, path) {
+					change.Breaking = true
+				}
+			}
+			if change.Breaking {
+				break
+			}
+		}
+	}
+	return d.changes, violations
+}
+
+// breakingAt reports whether a violation of type typ at path makes c breaking.
+func (c Change) breakingAt(typ, path string) bool {
+	switch c.Kind {
+	case PropertyAdded, PropertyRemoved:
+		if strings.HasPrefix(path, c.Path+"/") {
+			return true
+		}
+	case RefChanged, SubschemaAdded, SubschemaRemoved, SubschemaChanged:
+		// The whole schema at the path was replaced, as when a registry
+		// reference moves to another version
+		if path == c.Path || strings.HasPrefix(path, c.Path+"/") {
+			return true
+		}
+	}
+	// A branch that no longer accepts or is no longer accepted breaks
+	// through whatever changed within it
+	if (typ == CompositionNarrowed || typ == CompositionWidened) && (c.Path == path || strings.HasPrefix(c.Path, path+"/")) {
+		return true
+	}
+	if path != c.Path {
+		return false
+	}
+	return containsString(breakingViolations[c.Kind], typ)
+}
+
+// targetPath returns the path within root that path leads to when following
+// the local $refs on the way, the path itself if there are none.
+func targetPath(root map[string]interface{}, path string) string {
+	target := "#"
+	var node interface{} = root
+	follow := func() {
+		schema, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		resolved, ref := resolveRef(root, schema)
+		if ref != "" {
+			node, target = resolved, ref
+		}
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(path, "#"), "/")[1:] {
+		follow()
+		node = lookupPointer(node, "/"+token)
+		target += "/" + token
+	}
+	follow()
+	return target
+}
+
+type differ struct {
+	fromRoot, toRoot map[string]interface{}
+	// visiting holds the pairs of $refs being compared in place, to stop on
+	// recursive schemas
+	visiting map[string]bool
+	changes  []Change
+}
+
+func (d *differ) add(kind, path string, from, to interface{}, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Kind:    kind,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		From:    from,
+		To:      to,
+	})
+}
+
+func (d *differ) diff(from, to map[string]interface{}, path string) {
+	// Definitions are compared where they are, not where $refs lead
+	defer func(from, to map[string]interface{}) {
+		for _, key := range []string{"definitions", "$defs"} {
+			d.diffDefinitions(from, to, key, path)
+		}
+	}(from, to)
+
+	fromRef, _ := from["$ref"].(string)
+	toRef, _ := to["$ref"].(string)
+	if fromRef != toRef {
+		if fromRef != "" && toRef != "" {
+			d.add(RefChanged, path, from["$ref"], to["$ref"], "$ref changed from %s to %s", formatBound(from["$ref"]), formatBound(to["$ref"]))
+		}
+		// An inline schema replaced by a $ref to the same schema is no
+		// change, so what the $refs resolve to is compared
+		from, fromRef = resolveRef(d.fromRoot, from)
+		to, toRef = resolveRef(d.toRoot, to)
+		key := fromRef + " " + toRef
+		if d.visiting[key] {
+			return
+		}
+		d.visiting[key] = true
+		defer delete(d.visiting, key)
+	}
+	from = mergeAllOf(d.fromRoot, from)
+	to = mergeAllOf(d.toRoot, to)
+
+	fromTypes, toTypes := types(from), types(to)
+	if !sameStrings(fromTypes, toTypes) {
+		d.add(TypeChanged, path, from["type"], to["type"], "type changed from %s to %s", formatTypes(fromTypes), formatTypes(toTypes))
+	}
+
+	d.diffEnums(from, to, path)
+	for _, key := range constraints {
+		if !reflect.DeepEqual(from[key], to[key]) {
+			d.add(ConstraintChanged, path, from[key], to[key], "%s changed from %s to %s", key, formatBound(from[key]), formatBound(to[key]))
+		}
+	}
+	for _, key := range annotations {
+		if !reflect.DeepEqual(from[key], to[key]) {
+			d.add(AnnotationChanged, path, from[key], to[key], "%s changed", key)
+		}
+	}
+
+	d.diffObjects(from, to, path)
+	d.diffItems(from, to, path)
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		d.diffBranches(from, to, keyword, path)
+	}
+	for _, keyword := range []string{"not", "if", "then", "else"} {
+		d.diffSubschema(from[keyword], to[keyword], path+"/"+keyword, keyword)
+	}
+}
+
+func (d *differ) diffEnums(from, to map[string]interface{}, path string) {
+	fromEnum, fromOK := from["enum"].([]interface{})
+	toEnum, toOK := to["enum"].([]interface{})
+	switch {
+	case !fromOK && !toOK:
+		return
+	case !fromOK:
+		d.add(EnumAdded, path, nil, toEnum, "values restricted to enum %s", formatValues(toEnum))
+		return
+	case !toOK:
+		d.add(EnumRemoved, path, fromEnum, nil, "enum %s removed", formatValues(fromEnum))
+		return
+	}
+	if added := missingValues(toEnum, fromEnum); len(added) > 0 {
+		d.add(EnumValuesAdded, path, nil, added, "enum values %s added", formatValues(added))
+	}
+	if removed := missingValues(fromEnum, toEnum); len(removed) > 0 {
+		d.add(EnumValuesRemoved, path, removed, nil, "enum values %s removed", formatValues(removed))
+	}
+}
+
+func (d *differ) diffObjects(from, to map[string]interface{}, path string) {
+	fromRequired, toRequired := required(from), required(to)
+	fromProps, _ := from["properties"].(map[string]interface{})
+	toProps, _ := to["properties"].(map[string]interface{})
+
+	names := map[string]interface{}{}
+	for name := range fromProps {
+		names[name] = nil
+	}
+	for name := range toProps {
+		names[name] = nil
+	}
+	for name := range fromRequired {
+		names[name] = nil
+	}
+	for name := range toRequired {
+		names[name] = nil
+	}
+	for _, name := range sortedKeys(names) {
+		propPath := path + "/properties/" + escapePointer(name)
+		fromProp, inFrom := fromProps[name]
+		toProp, inTo := toProps[name]
+		switch {
+		case inFrom && !inTo:
+			d.add(PropertyRemoved, propPath, fromProp, nil, "property %q removed", name)
+		case !inFrom && inTo:
+			d.add(PropertyAdded, propPath, nil, toProp, "property %q added", name)
+		case inFrom && inTo:
+			d.diff(orEmpty(subschema(fromProp)), orEmpty(subschema(toProp)), propPath)
+		}
+		if toRequired[name] && !fromRequired[name] {
+			d.add(RequiredAdded, propPath, nil, nil, "property %q is now required", name)
+		} else if fromRequired[name] && !toRequired[name] {
+			d.add(RequiredRemoved, propPath, nil, nil, "property %q is no longer required", name)
+		}
+	}
+
+	fromAdditional, toAdditional := from["additionalProperties"], to["additionalProperties"]
+	additionalPath := path + "/additionalProperties"
+	fromSchema, toSchema := subschema(fromAdditional), subschema(toAdditional)
+	if fromSchema != nil && toSchema != nil {
+		d.diff(fromSchema, toSchema, additionalPath)
+	} else if !reflect.DeepEqual(fromAdditional, toAdditional) {
+		d.add(AdditionalPropertiesChanged, additionalPath, fromAdditional, toAdditional,
+			"additionalProperties changed from %s to %s", formatBound(fromAdditional), formatBound(toAdditional))
+	}
+
+	fromPatterns, _ := from["patternProperties"].(map[string]interface{})
+	toPatterns, _ := to["patternProperties"].(map[string]interface{})
+	patterns := map[string]interface{}{}
+	for pattern := range fromPatterns {
+		patterns[pattern] = nil
+	}
+	for pattern := range toPatterns {
+		patterns[pattern] = nil
+	}
+	for _, pattern := range sortedKeys(patterns) {
+		d.diffSubschema(fromPatterns[pattern], toPatterns[pattern], path+"/patternProperties/"+escapePointer(pattern),
+			fmt.Sprintf("pattern property %q", pattern))
+	}
+}
+
+// diffItems compares the items of arrays. The leading items of tuples, in
+// prefixItems or in an items array before 2020-12, are compared by position,
+// the remaining items as items or additionalItems. Paths follow the form of
+// the new version.
+func (d *differ) diffItems(from, to map[string]interface{}, path string) {
+	fromPrefix, fromItems := tupleItems(from)
+	toPrefix, toItems := tupleItems(to)
+	prefixKeyword, itemsKeyword := "prefixItems", "items"
+	if _, ok := to["items"].([]interface{}); ok {
+		prefixKeyword, itemsKeyword = "items", "additionalItems"
+	} else if _, ok := from["items"].([]interface{}); ok && to["prefixItems"] == nil {
+		prefixKeyword, itemsKeyword = "items", "additionalItems"
+	}
+	for i := 0; i < len(fromPrefix) || i < len(toPrefix); i++ {
+		var fromItem, toItem interface{}
+		if i < len(fromPrefix) {
+			fromItem = fromPrefix[i]
+		}
+		if i < len(toPrefix) {
+			toItem = toPrefix[i]
+		}
+		d.diffSubschema(fromItem, toItem, fmt.Sprintf("%s/%s/%d", path, prefixKeyword, i), fmt.Sprintf("item %d", i))
+	}
+	d.diffSubschema(fromItems, toItems, path+"/"+itemsKeyword, itemsKeyword)
+}
+
+// tupleItems returns the schemas of the leading items of schema, from
+// prefixItems or an items array, and the schema of the remaining items.
+func tupleItems(schema map[string]interface{}) ([]interface{}, interface{}) {
+	if prefix, ok := schema["items"].([]interface{}); ok {
+		return prefix, schema["additionalItems"]
+	}
+	prefix, _ := schema["prefixItems"].([]interface{})
+	return prefix, schema["items"]
+}
+
+// diffBranches compares the branches of the anyOf or oneOf keyword. Branches
+// found unchanged in the other version are skipped wherever they are, the
+// others are compared with the branch at the same position, if it changed
+// too, or reported as added or removed.
+func (d *differ) diffBranches(from, to map[string]interface{}, keyword, path string) {
+	fromBranches, fromOK := from[keyword].([]interface{})
+	toBranches, toOK := to[keyword].([]interface{})
+	switch {
+	case !fromOK && !toOK:
+		return
+	case !fromOK:
+		d.add(SubschemaAdded, path+"/"+keyword, nil, toBranches, "%s added", keyword)
+		return
+	case !toOK:
+		d.add(SubschemaRemoved, path+"/"+keyword, fromBranches, nil, "%s removed", keyword)
+		return
+	}
+
+	fromChanged, toChanged := map[int]bool{}, map[int]bool{}
+	for i, branch := range fromBranches {
+		fromChanged[i] = len(missingValues([]interface{}{branch}, toBranches)) > 0
+	}
+	for i, branch := range toBranches {
+		toChanged[i] = len(missingValues([]interface{}{branch}, fromBranches)) > 0
+	}
+	for i := 0; i < len(fromBranches) || i < len(toBranches); i++ {
+		var fromBranch, toBranch interface{}
+		if fromChanged[i] {
+			fromBranch = fromBranches[i]
+		}
+		if toChanged[i] {
+			toBranch = toBranches[i]
+		}
+		d.diffSubschema(fromBranch, toBranch, fmt.Sprintf("%s/%s/%d", path, keyword, i), fmt.Sprintf("branch %d of %s", i, keyword))
+	}
+}
+
+// diffSubschema compares the subschemas at path, nil when absent, and
+// reports those added, removed or changed from or to a boolean schema.
+func (d *differ) diffSubschema(from, to interface{}, path, name string) {
+	switch {
+	case from == nil && to == nil:
+	case from == nil:
+		d.add(SubschemaAdded, path, nil, to, "%s added", name)
+	case to == nil:
+		d.add(SubschemaRemoved, path, from, nil, "%s removed", name)
+	default:
+		fromSchema, toSchema := subschema(from), subschema(to)
+		if fromSchema != nil && toSchema != nil {
+			d.diff(fromSchema, toSchema, path)
+		} else if !reflect.DeepEqual(from, to) {
+			d.add(SubschemaChanged, path, from, to, "%s changed from %s to %s", name, formatBound(from), formatBound(to))
+		}
+	}
+}
+
+func (d *differ) diffDefinitions(from, to map[string]interface{}, key, path string) {
+	fromDefs, _ := from[key].(map[string]interface{})
+	toDefs, _ := to[key].(map[string]interface{})
+	names := map[string]interface{}{}
+	for name := range fromDefs {
+		names[name] = nil
+	}
+	for name := range toDefs {
+		names[name] = nil
+	}
+	for _, name := range sortedKeys(names) {
+		defPath := path + "/" + key + "/" + escapePointer(name)
+		fromDef, inFrom := fromDefs[name]
+		toDef, inTo := toDefs[name]
+		switch {
+		case inFrom && !inTo:
+			d.add(DefinitionRemoved, defPath, fromDef, nil, "definition %q removed", name)
+		case !inFrom && inTo:
+			d.add(DefinitionAdded, defPath, nil, toDef, "definition %q added", name)
+		default:
+			d.diff(orEmpty(subschema(fromDef)), orEmpty(subschema(toDef)), defPath)
+		}
+	}
+}
+
+// FormatDiff renders changes like a unified diff, one hunk per change with
+// the old value prefixed by "-" and the new one by "+".
+func FormatDiff(fromLabel, toLabel string, changes []Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, change := range changes {
+		breaking := ""
+		if change.Breaking {
+			breaking = " BREAKING"
+		}
+		fmt.Fprintf(&b, "@@ %s @@ %s%s: %s\n", change.Path, change.Kind, breaking, change.Message)
+		if change.From != nil {
+			fmt.Fprintf(&b, "-%s\n", valueKey(change.From))
+		}
+		if change.To != nil {
+			fmt.Fprintf(&b, "+%s\n", valueKey(change.To))
+		}
+	}
+	return b.String()
+}
+
+// subschema returns v if it is a schema object, nil otherwise.
+func subschema(v interface{}) map[string]interface{} {
+	schema, _ := v.(map[string]interface{})
+	return schema
+}
+
+func orEmpty(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return map[string]interface{}{}
+	}
+	return schema
+}
+
+// missingValues returns the values of a that are not in b.
+func missingValues(a, b []interface{}) []interface{} {
+	present := map[string]bool{}
+	for _, v := range b {
+		present[valueKey(v)] = true
+	}
+	missing := []interface{}{}
+	for _, v := range a {
+		if !present[valueKey(v)] {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func sameStrings(a, b []string) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, s := range a {
+		seen[s] = true
+	}
+	for _, s := range b {
+		if !seen[s] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,365 @@
+package compat
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustSchema(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatalf("invalid schema %s: %v", s, err)
+	}
+	return doc
+}
+
+func TestDiffBreakingThroughRef(t *testing.T) {
+	from := mustSchema(t, `{
+		"type": "object",
+		"properties": {"a": {"$ref": "#/definitions/A"}},
+		"definitions": {"A": {"type": "object", "properties": {"z": {"type": "string"}}}}
+	}`)
+	to := mustSchema(t, `{
+		"type": "object",
+		"properties": {"a": {"$ref": "#/definitions/A"}},
+		"definitions": {"A": {"type": "object", "properties": {"z": {"type": "integer"}}}}
+	}`)
+
+	changes, violations := Diff(Backward, from, to)
+	if len(violations) == 0 {
+		t.Fatal("expected violations")
+	}
+	var found bool
+	for _, change := range changes {
+		if change.Kind == TypeChanged && change.Path == "#/definitions/A/properties/z" {
+			found = true
+			if !change.Breaking {
+				t.Errorf("change at %s is not breaking, violations: %v", change.Path, violations)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("no TYPE_CHANGED at #/definitions/A/properties/z in %+v", changes)
+	}
+}
+
+// TestDiffRefReplaced covers registry references bundled by Resolve, which
+// point to another definition when the referenced version changes.
+func TestDiffRefReplaced(t *testing.T) {
+	from := mustSchema(t, `{
+		"properties": {"a": {"$ref": "#/definitions/A_v1"}},
+		"definitions": {"A_v1": {"properties": {"z": {"type": "string"}}}}
+	}`)
+	to := mustSchema(t, `{
+		"properties": {"a": {"$ref": "#/definitions/A_v2"}},
+		"definitions": {"A_v2": {"properties": {"z": {"type": "integer"}}}}
+	}`)
+
+	changes, _ := Diff(Backward, from, to)
+	for _, change := range changes {
+		if change.Kind == RefChanged && change.Path == "#/properties/a" {
+			if !change.Breaking {
+				t.Errorf("change at %s is not breaking", change.Path)
+			}
+			return
+		}
+	}
+	t.Fatalf("no REF_CHANGED at #/properties/a in %+v", changes)
+}
+
+func TestTargetPath(t *testing.T) {
+	root := mustSchema(t, `{
+		"properties": {
+			"a": {"$ref": "#/definitions/A"},
+			"b": {"$ref": "#/definitions/B"},
+			"c": {"type": "string"}
+		},
+		"definitions": {
+			"A": {"properties": {"z": {"type": "string"}}},
+			"B": {"$ref": "#/definitions/A"}
+		}
+	}`)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"#", "#"},
+		{"#/properties/c", "#/properties/c"},
+		{"#/properties/a", "#/definitions/A"},
+		{"#/properties/a/properties/z", "#/definitions/A/properties/z"},
+		{"#/properties/b/properties/z", "#/definitions/A/properties/z"},
+		{"#/properties/missing/type", "#/properties/missing/type"},
+	}
+	for _, tt := range tests {
+		if got := targetPath(root, tt.path); got != tt.want {
+			t.Errorf("targetPath(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type change struct {
+		Kind     string
+		Path     string
+		Breaking bool
+	}
+	tests := []struct {
+		name  string
+		level Level
+		from  string
+		to    string
+		want  []change
+	}{
+		{
+			name:  "identical",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			want:  []change{},
+		},
+		{
+			name:  "optional property added",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, "additionalProperties": false}`,
+			want:  []change{{PropertyAdded, "#/properties/b", false}},
+		},
+		{
+			name:  "property added to an open object",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}}`,
+			want:  []change{{PropertyAdded, "#/properties/b", true}},
+		},
+		{
+			name:  "required property added",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, "required": ["b"], "additionalProperties": false}`,
+			want: []change{
+				{PropertyAdded, "#/properties/b", false},
+				{RequiredAdded, "#/properties/b", true},
+			},
+		},
+		{
+			name:  "type changed",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "integer"}}}`,
+			want:  []change{{TypeChanged, "#/properties/a", true}},
+		},
+		{
+			name:  "enum values added backward",
+			level: Backward,
+			from:  `{"enum": ["a"]}`,
+			to:    `{"enum": ["a", "b"]}`,
+			want:  []change{{EnumValuesAdded, "#", false}},
+		},
+		{
+			name:  "enum values added forward",
+			level: Forward,
+			from:  `{"enum": ["a"]}`,
+			to:    `{"enum": ["a", "b"]}`,
+			want:  []change{{EnumValuesAdded, "#", true}},
+		},
+		{
+			name:  "constraint tightened",
+			level: Backward,
+			from:  `{"type": "string", "maxLength": 10}`,
+			to:    `{"type": "string", "maxLength": 5}`,
+			want:  []change{{ConstraintChanged, "#", true}},
+		},
+		{
+			name:  "const changed",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"kind": {"const": "a"}}}`,
+			to:    `{"type": "object", "properties": {"kind": {"const": "b"}}}`,
+			want:  []change{{ConstraintChanged, "#/properties/kind", true}},
+		},
+		{
+			name:  "const added",
+			level: Backward,
+			from:  `{"type": "string"}`,
+			to:    `{"type": "string", "const": "a"}`,
+			want:  []change{{ConstraintChanged, "#", true}},
+		},
+		{
+			name:  "multipleOf changed",
+			level: Forward,
+			from:  `{"type": "integer", "multipleOf": 4}`,
+			to:    `{"type": "integer", "multipleOf": 2}`,
+			want:  []change{{ConstraintChanged, "#", true}},
+		},
+		{
+			name:  "multipleOf tightened to a multiple",
+			level: Forward,
+			from:  `{"type": "integer", "multipleOf": 2}`,
+			to:    `{"type": "integer", "multipleOf": 4}`,
+			want:  []change{{ConstraintChanged, "#", false}},
+		},
+		{
+			name:  "anyOf narrowed",
+			level: Backward,
+			from:  `{"anyOf": [{"type": "string"}, {"type": "integer"}, {"type": "boolean"}]}`,
+			to:    `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`,
+			want:  []change{{SubschemaRemoved, "#/anyOf/1", true}},
+		},
+		{
+			name:  "anyOf branch changed",
+			level: Backward,
+			from:  `{"anyOf": [{"type": "string"}, {"type": "integer", "maximum": 10}]}`,
+			to:    `{"anyOf": [{"type": "string"}, {"type": "integer", "maximum": 5}]}`,
+			want:  []change{{ConstraintChanged, "#/anyOf/1", true}},
+		},
+		{
+			name:  "oneOf widened",
+			level: Backward,
+			from:  `{"oneOf": [{"type": "string"}]}`,
+			to:    `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+			want:  []change{{SubschemaAdded, "#/oneOf/1", false}},
+		},
+		{
+			name:  "oneOf added",
+			level: Backward,
+			from:  `{}`,
+			to:    `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+			want:  []change{{SubschemaAdded, "#/oneOf", true}},
+		},
+		{
+			name:  "allOf merged",
+			level: Backward,
+			from:  `{"allOf": [{"type": "object", "properties": {"a": {"type": "string"}}}, {"required": ["a"]}]}`,
+			to:    `{"type": "object", "properties": {"a": {"type": "integer"}}, "required": ["a"]}`,
+			want:  []change{{TypeChanged, "#/properties/a", true}},
+		},
+		{
+			name:  "not changed",
+			level: Backward,
+			from:  `{"not": {"type": "string"}}`,
+			to:    `{"not": {"type": ["string", "integer"]}}`,
+			want:  []change{{TypeChanged, "#/not", true}},
+		},
+		{
+			name:  "if then else",
+			level: Backward,
+			from:  `{"if": {"type": "string"}, "then": {"minLength": 1}}`,
+			to:    `{"if": {"type": "string"}, "then": {"minLength": 2}, "else": {"minimum": 0}}`,
+			want: []change{
+				{ConstraintChanged, "#/then", false},
+				{SubschemaAdded, "#/else", false},
+			},
+		},
+		{
+			name:  "pattern properties",
+			level: Backward,
+			from:  `{"patternProperties": {"^a": {"type": "string"}, "^b": {"type": "string"}}}`,
+			to:    `{"patternProperties": {"^a": {"type": "integer"}, "^c": {"type": "string"}}}`,
+			want: []change{
+				{TypeChanged, "#/patternProperties/^a", false},
+				{SubschemaRemoved, "#/patternProperties/^b", false},
+				{SubschemaAdded, "#/patternProperties/^c", false},
+			},
+		},
+		{
+			name:  "prefixItems",
+			level: Backward,
+			from:  `{"type": "array", "prefixItems": [{"type": "string"}], "items": false}`,
+			to:    `{"type": "array", "prefixItems": [{"type": "string"}, {"type": "integer"}], "items": true}`,
+			want: []change{
+				{SubschemaAdded, "#/prefixItems/1", false},
+				{SubschemaChanged, "#/items", false},
+			},
+		},
+		{
+			name:  "items array",
+			level: Backward,
+			from:  `{"type": "array", "items": [{"type": "string"}, {"type": "integer"}]}`,
+			to:    `{"type": "array", "items": [{"type": "string"}, {"type": "number"}], "additionalItems": false}`,
+			want: []change{
+				{TypeChanged, "#/items/1", false},
+				{SubschemaAdded, "#/additionalItems", false},
+			},
+		},
+		{
+			name:  "items added",
+			level: Backward,
+			from:  `{"type": "array"}`,
+			to:    `{"type": "array", "items": {"type": "string"}}`,
+			want:  []change{{SubschemaAdded, "#/items", true}},
+		},
+		{
+			name:  "inline schema moved to a definition",
+			level: Full,
+			from:  `{"type": "object", "properties": {"a": {"type": "object", "properties": {"z": {"type": "string"}}}}}`,
+			to:    `{"type": "object", "properties": {"a": {"$ref": "#/$defs/A"}}, "$defs": {"A": {"type": "object", "properties": {"z": {"type": "string"}}}}}`,
+			want:  []change{{DefinitionAdded, "#/$defs/A", false}},
+		},
+		{
+			name:  "inline schema changed into a definition",
+			level: Backward,
+			from:  `{"type": "object", "properties": {"a": {"type": "object", "properties": {"z": {"type": "string"}}}}}`,
+			to:    `{"type": "object", "properties": {"a": {"$ref": "#/$defs/A"}}, "$defs": {"A": {"type": "object", "properties": {"z": {"type": "integer"}}}}}`,
+			want: []change{
+				{TypeChanged, "#/properties/a/properties/z", true},
+				{DefinitionAdded, "#/$defs/A", false},
+			},
+		},
+		{
+			name:  "recursive definitions swapped",
+			level: Full,
+			from:  `{"$ref": "#/$defs/A", "$defs": {"A": {"type": "object", "properties": {"next": {"$ref": "#/$defs/A"}}}}}`,
+			to:    `{"$ref": "#/$defs/B", "$defs": {"B": {"type": "object", "properties": {"next": {"$ref": "#/$defs/B"}}}}}`,
+			want: []change{
+				{RefChanged, "#", false},
+				{RefChanged, "#/properties/next", false},
+				{DefinitionRemoved, "#/$defs/A", false},
+				{DefinitionAdded, "#/$defs/B", false},
+			},
+		},
+		{
+			name:  "annotation changed",
+			level: Full,
+			from:  `{"type": "string", "description": "old"}`,
+			to:    `{"type": "string", "description": "new"}`,
+			want:  []change{{AnnotationChanged, "#", false}},
+		},
+		{
+			name:  "definition added",
+			level: Backward,
+			from:  `{"type": "object"}`,
+			to:    `{"type": "object", "definitions": {"A": {"type": "string"}}}`,
+			want:  []change{{DefinitionAdded, "#/definitions/A", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, _ := Diff(tt.level, mustSchema(t, tt.from), mustSchema(t, tt.to))
+			got := []change{}
+			for _, c := range changes {
+				got = append(got, change{c.Kind, c.Path, c.Breaking})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	changes := []Change{{
+		Kind:     ConstraintChanged,
+		Path:     "#/properties/note",
+		Message:  "maxLength changed from 10 to 5",
+		From:     10.0,
+		To:       5.0,
+		Breaking: true,
+	}}
+	want := "--- orders v1\n+++ orders v2\n" +
+		"@@ #/properties/note @@ CONSTRAINT_CHANGED BREAKING: maxLength changed from 10 to 5\n" +
+		"-10\n+5\n"
+	if got := FormatDiff("orders v1", "orders v2", changes); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
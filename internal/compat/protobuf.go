@@ -0,0 +1,344 @@
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// Protobuf violation types. Fields are matched by number, as that is all the
+// wire format carries.
+const (
+	ProtobufPackageChanged            = "PACKAGE_CHANGED"
+	ProtobufMessageRemoved            = "MESSAGE_REMOVED"
+	ProtobufFieldKindChanged          = "FIELD_KIND_CHANGED"
+	ProtobufFieldScalarKindChanged    = "FIELD_SCALAR_KIND_CHANGED"
+	ProtobufFieldNamedTypeChanged     = "FIELD_NAMED_TYPE_CHANGED"
+	ProtobufFieldLabelChanged         = "FIELD_LABEL_CHANGED"
+	ProtobufRequiredFieldAdded        = "REQUIRED_FIELD_ADDED"
+	ProtobufRequiredFieldRemoved      = "REQUIRED_FIELD_REMOVED"
+	ProtobufFieldMovedToExistingOneof = "FIELD_MOVED_TO_EXISTING_ONEOF"
+)
+
+// protoWireKinds groups the scalar types that share a wire encoding and can
+// be read as one another.
+var protoWireKinds = map[string]string{
+	"int32":    "varint",
+	"int64":    "varint",
+	"uint32":   "varint",
+	"uint64":   "varint",
+	"bool":     "varint",
+	"sint32":   "zigzag",
+	"sint64":   "zigzag",
+	"fixed32":  "fixed32",
+	"sfixed32": "fixed32",
+	"fixed64":  "fixed64",
+	"sfixed64": "fixed64",
+	"float":    "float",
+	"double":   "double",
+	"string":   "bytes",
+	"bytes":    "bytes",
+}
+
+type protoFile struct {
+	Package string
+	// Messages maps the name of every message, nested ones as
+	// "Outer.Inner", to its fields by number
+	Messages map[string]map[int]*protoField
+	Enums    map[string]bool
+}
+
+type protoField struct {
+	Name string
+	// Type is the scalar type or the resolved name of a message or enum,
+	// for maps the type of the values
+	Type     string
+	KeyType  string
+	Map      bool
+	Repeated bool
+	Required bool
+	Oneof    string
+}
+
+// kind returns the wire kind of the field type.
+func (f *protoFile) kind(t string) string {
+	if kind, ok := protoWireKinds[t]; ok {
+		return kind
+	}
+	if f.Enums[t] {
+		return "varint"
+	}
+	return "message"
+}
+
+// CheckProtobuf compares a candidate .proto schema against the previous
+// versions of the same schema, ordered from oldest to newest, and reports the
+// changes that break reading data in the binary wire format.
+func CheckProtobuf(level Level, candidate string, previous []string) ([]Violation, error) {
+	candidateFile, err := parseProtobuf(candidate)
+	if err != nil {
+		return nil, err
+	}
+	previousFiles := make([]*protoFile, 0, len(previous))
+	for _, p := range previous {
+		file, err := parseProtobuf(p)
+		if err != nil {
+			return nil, err
+		}
+		previousFiles = append(previousFiles, file)
+	}
+
+	return checkVersions(level, len(previousFiles), func(backward bool, i int) []Violation {
+		c := &protoChecker{backward: backward}
+		if backward {
+			c.compare(candidateFile, previousFiles[i])
+		} else {
+			c.compare(previousFiles[i], candidateFile)
+		}
+		return c.violations
+	}), nil
+}
+
+// protoChecker collects the violations found while reading data written with
+// a writer schema using a reader schema. Changes that break both directions
+// are reported with the same message by either pass, so they are deduplicated.
+type protoChecker struct {
+	backward   bool
+	violations []Violation
+}
+
+func (c *protoChecker) report(typ, path, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{
+		Type:    typ,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// versions returns the reader and writer ordered as (previous, new).
+func (c *protoChecker) versions(reader, writer *protoFile) (*protoFile, *protoFile) {
+	if c.backward {
+		return writer, reader
+	}
+	return reader, writer
+}
+
+func (c *protoChecker) compare(reader, writer *protoFile) {
+	previous, current := c.versions(reader, writer)
+	if reader.Package != writer.Package {
+		c.report(ProtobufPackageChanged, "#", "package changed from %q to %q", previous.Package, current.Package)
+	}
+
+	for _, name := range sortedMessageNames(writer.Messages) {
+		writerFields := writer.Messages[name]
+		readerFields, ok := reader.Messages[name]
+		if !ok {
+			if c.backward {
+				c.report(ProtobufMessageRemoved, name, "message %s was removed", name)
+			}
+			continue
+		}
+
+		for _, number := range sortedFieldNumbers(readerFields) {
+			readerField := readerFields[number]
+			writerField, ok := writerFields[number]
+			if !ok {
+				if readerField.Required {
+					typ, forwardType := ProtobufRequiredFieldAdded, ProtobufRequiredFieldRemoved
+					if !c.backward {
+						typ = forwardType
+					}
+					c.report(typ, name+"."+readerField.Name, "required field %s = %d is missing in the data", readerField.Name, number)
+				}
+				continue
+			}
+			previousField, currentField := readerField, writerField
+			if c.backward {
+				previousField, currentField = writerField, readerField
+			}
+			c.compareFields(previous, current, previousField, currentField, name+"."+currentField.Name, number)
+		}
+	}
+}
+
+// compareFields compares the previous and new definition of field number.
+func (c *protoChecker) compareFields(previous, current *protoFile, previousField, currentField *protoField, path string, number int) {
+	if previousField.Map != currentField.Map {
+		c.report(ProtobufFieldKindChanged, path, "field %d changed between a map and a %s field", number, describeProtoField(previousField, currentField))
+		return
+	}
+	if previousField.Map && previous.kind(previousField.KeyType) != current.kind(currentField.KeyType) {
+		c.report(ProtobufFieldScalarKindChanged, path, "map key of field %d changed from %s to %s", number, previousField.KeyType, currentField.KeyType)
+	}
+
+	previousKind, currentKind := previous.kind(previousField.Type), current.kind(currentField.Type)
+	switch {
+	case previousKind == "message" && currentKind == "message", previous.Enums[previousField.Type] && current.Enums[currentField.Type]:
+		if previousField.Type != currentField.Type {
+			c.report(ProtobufFieldNamedTypeChanged, path, "type of field %d changed from %s to %s", number, previousField.Type, currentField.Type)
+		}
+	case previousKind == "message" || currentKind == "message":
+		c.report(ProtobufFieldKindChanged, path, "type of field %d changed from %s to %s", number, previousField.Type, currentField.Type)
+	case previousKind != currentKind:
+		c.report(ProtobufFieldScalarKindChanged, path, "type of field %d changed from %s to %s, which are encoded differently", number, previousField.Type, currentField.Type)
+	}
+
+	if previousField.Repeated != currentField.Repeated {
+		c.report(ProtobufFieldLabelChanged, path, "field %d changed between repeated and singular", number)
+	}
+	if currentField.Oneof != "" && previousField.Oneof == "" && previous.hasOneof(path, currentField.Oneof) {
+		c.report(ProtobufFieldMovedToExistingOneof, path, "field %d was moved into the existing oneof %s", number, currentField.Oneof)
+	}
+}
+
+// hasOneof reports whether the message holding the field at path has a oneof
+// with the given name.
+func (f *protoFile) hasOneof(path, oneof string) bool {
+	message := path[:strings.LastIndex(path, ".")]
+	for _, field := range f.Messages[message] {
+		if field.Oneof == oneof {
+			return true
+		}
+	}
+	return false
+}
+
+func describeProtoField(fields ...*protoField) string {
+	for _, field := range fields {
+		if !field.Map {
+			return field.Type
+		}
+	}
+	return "map"
+}
+
+func sortedMessageNames(messages map[string]map[int]*protoField) []string {
+	names := make([]string, 0, len(messages))
+	for name := range messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedFieldNumbers(fields map[int]*protoField) []int {
+	numbers := make([]int, 0, len(fields))
+	for number := range fields {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// parseProtobuf parses a .proto schema into its messages and enums, with the
+// types of fields resolved to the names of the messages and enums they refer
+// to.
+func parseProtobuf(source string) (*protoFile, error) {
+	definition, err := proto.NewParser(strings.NewReader(source)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	file := &protoFile{
+		Messages: map[string]map[int]*protoField{},
+		Enums:    map[string]bool{},
+	}
+	// Collect the declared names first, fields may refer to types declared
+	// further down
+	file.declare(definition.Elements, "")
+	for _, element := range definition.Elements {
+		if pkg, ok := element.(*proto.Package); ok {
+			file.Package = pkg.Name
+		}
+	}
+	file.collect(definition.Elements, "")
+	return file, nil
+}
+
+func (f *protoFile) declare(elements []proto.Visitee, scope string) {
+	for _, element := range elements {
+		switch element := element.(type) {
+		case *proto.Message:
+			if element.IsExtend {
+				continue
+			}
+			name := scope + element.Name
+			f.Messages[name] = map[int]*protoField{}
+			f.declare(element.Elements, name+".")
+		case *proto.Enum:
+			f.Enums[scope+element.Name] = true
+		}
+	}
+}
+
+func (f *protoFile) collect(elements []proto.Visitee, scope string) {
+	for _, element := range elements {
+		message, ok := element.(*proto.Message)
+		if !ok || message.IsExtend {
+			continue
+		}
+		name := scope + message.Name
+		fields := f.Messages[name]
+		for _, element := range message.Elements {
+			switch element := element.(type) {
+			case *proto.NormalField:
+				fields[element.Sequence] = &protoField{
+					Name:     element.Name,
+					Type:     f.resolve(element.Type, name),
+					Repeated: element.Repeated,
+					Required: element.Required,
+				}
+			case *proto.MapField:
+				fields[element.Sequence] = &protoField{
+					Name:    element.Name,
+					Type:    f.resolve(element.Type, name),
+					KeyType: element.KeyType,
+					Map:     true,
+				}
+			case *proto.Oneof:
+				for _, oneofElement := range element.Elements {
+					if field, ok := oneofElement.(*proto.OneOfField); ok {
+						fields[field.Sequence] = &protoField{
+							Name:  field.Name,
+							Type:  f.resolve(field.Type, name),
+							Oneof: element.Name,
+						}
+					}
+				}
+			}
+		}
+		f.collect(message.Elements, name+".")
+	}
+}
+
+// resolve returns the name of the message or enum a field type refers to,
+// searching the scopes from the message outwards as protoc does. Scalar and
+// unknown types, such as imported ones, are returned as written.
+func (f *protoFile) resolve(t, message string) string {
+	if _, ok := protoWireKinds[t]; ok {
+		return t
+	}
+	t = strings.TrimPrefix(t, ".")
+	if f.Package != "" {
+		t = strings.TrimPrefix(t, f.Package+".")
+	}
+	scope := message
+	for {
+		candidate := t
+		if scope != "" {
+			candidate = scope + "." + t
+		}
+		if _, ok := f.Messages[candidate]; ok || f.Enums[candidate] {
+			return candidate
+		}
+		if scope == "" {
+			return t
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
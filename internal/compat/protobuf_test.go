@@ -0,0 +1,98 @@
+package compat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckProtobuf(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     Level
+		previous  []string
+		candidate string
+		want      []string
+	}{
+		{
+			name:      "field added",
+			level:     Full,
+			previous:  []string{`syntax = "proto3"; message M { string a = 1; }`},
+			candidate: `syntax = "proto3"; message M { string a = 1; int32 b = 2; }`,
+			want:      []string{},
+		},
+		{
+			name:      "field renamed",
+			level:     Full,
+			previous:  []string{`syntax = "proto3"; message M { string a = 1; }`},
+			candidate: `syntax = "proto3"; message M { string b = 1; }`,
+			want:      []string{},
+		},
+		{
+			name:      "same wire kind",
+			level:     Full,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; message M { int64 a = 1; }`,
+			want:      []string{},
+		},
+		{
+			name:      "scalar kind changed",
+			level:     Backward,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; message M { string a = 1; }`,
+			want:      []string{ProtobufFieldScalarKindChanged},
+		},
+		{
+			name:      "package changed",
+			level:     Backward,
+			previous:  []string{`syntax = "proto3"; package a; message M { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; package b; message M { int32 a = 1; }`,
+			want:      []string{ProtobufPackageChanged},
+		},
+		{
+			name:      "message removed",
+			level:     Backward,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; } message N { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; message M { int32 a = 1; }`,
+			want:      []string{ProtobufMessageRemoved},
+		},
+		{
+			name:      "label changed",
+			level:     Backward,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; message M { repeated int32 a = 1; }`,
+			want:      []string{ProtobufFieldLabelChanged},
+		},
+		{
+			name:      "required field added",
+			level:     Backward,
+			previous:  []string{`syntax = "proto2"; message M { optional int32 a = 1; }`},
+			candidate: `syntax = "proto2"; message M { optional int32 a = 1; required int32 b = 2; }`,
+			want:      []string{ProtobufRequiredFieldAdded},
+		},
+		{
+			name:      "field moved to existing oneof",
+			level:     Backward,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; oneof o { int32 b = 2; } }`},
+			candidate: `syntax = "proto3"; message M { oneof o { int32 a = 1; int32 b = 2; } }`,
+			want:      []string{ProtobufFieldMovedToExistingOneof},
+		},
+		{
+			name:      "none",
+			level:     None,
+			previous:  []string{`syntax = "proto3"; message M { int32 a = 1; }`},
+			candidate: `syntax = "proto3"; message M { string a = 1; }`,
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := CheckProtobuf(tt.level, tt.candidate, tt.previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := violationTypes(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v: %v", got, tt.want, violations)
+			}
+		})
+	}
+}
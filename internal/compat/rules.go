@@ -0,0 +1,660 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Violation types. Each rule reports one type when the new schema cannot read
+// data of the previous one (backward) and its counterpart when the previous
+// schema cannot read data of the new one (forward).
+const (
+	RequiredPropertyAdded        = "REQUIRED_PROPERTY_ADDED"
+	RequiredPropertyRemoved      = "REQUIRED_PROPERTY_REMOVED"
+	PropertyAdded                = "PROPERTY_ADDED"
+	PropertyRemoved              = "PROPERTY_REMOVED"
+	TypeNarrowed                 = "TYPE_NARROWED"
+	TypeWidened                  = "TYPE_WIDENED"
+	EnumNarrowed                 = "ENUM_NARROWED"
+	EnumWidened                  = "ENUM_WIDENED"
+	ConstraintTightened          = "CONSTRAINT_TIGHTENED"
+	ConstraintLoosened           = "CONSTRAINT_LOOSENED"
+	PatternChanged               = "PATTERN_CHANGED"
+	AdditionalPropertiesNarrowed = "ADDITIONAL_PROPERTIES_NARROWED"
+	AdditionalPropertiesWidened  = "ADDITIONAL_PROPERTIES_WIDENED"
+	// CompositionNarrowed and CompositionWidened are reported for a branch
+	// of anyOf or oneOf, or a not, that one version accepts and the other
+	// does not
+	CompositionNarrowed = "COMPOSITION_NARROWED"
+	CompositionWidened  = "COMPOSITION_WIDENED"
+	// SchemaTypeChanged is reported for a new version of another type,
+	// whatever the schema type
+	SchemaTypeChanged = "SCHEMA_TYPE_CHANGED"
+)
+
+var (
+	lowerBounds = []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"}
+	upperBounds = []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"}
+	// annotationKeywords are the keywords that do not constrain documents
+	annotationKeywords = map[string]bool{
+		"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+		"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+	}
+)
+
+// checker collects the violations found while comparing a reader schema with
+// a writer schema. With backward set the reader is the new version, otherwise
+// the reader is the previous version.
+type checker struct {
+	backward   bool
+	readerRoot map[string]interface{}
+	writerRoot map[string]interface{}
+	// visiting holds the pairs of $refs being compared, to stop on
+	// recursive schemas
+	visiting   map[string]bool
+	violations []Violation
+}
+
+func newChecker(backward bool, readerRoot, writerRoot map[string]interface{}) *checker {
+	return &checker{
+		backward:   backward,
+		readerRoot: readerRoot,
+		writerRoot: writerRoot,
+		visiting:   map[string]bool{},
+	}
+}
+
+func (c *checker) report(backwardType, forwardType, path, format string, args ...interface{}) {
+	typ := backwardType
+	if !c.backward {
+		typ = forwardType
+	}
+	c.violations = append(c.violations, Violation{
+		Type:    typ,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// versions returns the reader and writer values ordered as (previous, new).
+func (c *checker) versions(reader, writer interface{}) (interface{}, interface{}) {
+	if c.backward {
+		return writer, reader
+	}
+	return reader, writer
+}
+
+// compare reports every way in which a document that is valid under writer
+// can be rejected by reader. The branches of allOf are merged into the schema
+// holding them, so what they constrain is compared as if written there.
+func (c *checker) compare(reader, writer map[string]interface{}, path string) {
+	reader, readerRef := resolveRef(c.readerRoot, reader)
+	writer, writerRef := resolveRef(c.writerRoot, writer)
+	if readerRef != "" || writerRef != "" {
+		key := readerRef + " " + writerRef
+		if c.visiting[key] {
+			return
+		}
+		c.visiting[key] = true
+		defer delete(c.visiting, key)
+	}
+	reader = mergeAllOf(c.readerRoot, reader)
+	writer = mergeAllOf(c.writerRoot, writer)
+
+	// A writer with alternatives writes documents of each of them, which a
+	// reader without alternatives must all accept
+	if keyword, branches := alternatives(writer); branches != nil {
+		if readerKeyword, _ := alternatives(reader); readerKeyword == "" {
+			base := without(writer, keyword)
+			for i, branch := range branches {
+				c.compare(reader, merge(base, branch), fmt.Sprintf("%s/%s/%d", path, keyword, i))
+			}
+			return
+		}
+	}
+
+	c.compareTypes(reader, writer, path)
+	c.compareEnums(reader, writer, path)
+	c.compareConst(reader, writer, path)
+	c.compareBounds(reader, writer, path)
+	c.compareAlternatives(reader, writer, path)
+	c.compareNot(reader, writer, path)
+	if allowsType(writer, "object") {
+		c.compareObjects(reader, writer, path)
+	}
+	if allowsType(writer, "array") {
+		if readerItems, ok := reader["items"].(map[string]interface{}); ok {
+			writerItems, ok := writer["items"].(map[string]interface{})
+			if !ok {
+				writerItems = map[string]interface{}{}
+			}
+			c.compare(readerItems, writerItems, path+"/items")
+		}
+	}
+}
+
+func (c *checker) compareTypes(reader, writer map[string]interface{}, path string) {
+	readerTypes := types(reader)
+	if readerTypes == nil {
+		return
+	}
+	writerTypes := types(writer)
+	if writerTypes != nil {
+		accepted := true
+		for _, t := range writerTypes {
+			if !acceptsType(readerTypes, t) {
+				accepted = false
+				break
+			}
+		}
+		if accepted {
+			return
+		}
+	}
+	previous, current := c.versions(readerTypes, writerTypes)
+	c.report(TypeNarrowed, TypeWidened, path, "type changed from %s to %s", formatTypes(previous), formatTypes(current))
+}
+
+func (c *checker) compareEnums(reader, writer map[string]interface{}, path string) {
+	readerEnum, ok := reader["enum"].([]interface{})
+	if !ok {
+		return
+	}
+	writerEnum, ok := writer["enum"].([]interface{})
+	if writerConst, isConst := writer["const"]; !ok && isConst {
+		writerEnum, ok = []interface{}{writerConst}, true
+	}
+	if !ok {
+		if c.backward {
+			c.report(EnumNarrowed, EnumWidened, path, "values restricted to enum %s", formatValues(readerEnum))
+		} else {
+			c.report(EnumNarrowed, EnumWidened, path, "enum %s removed", formatValues(readerEnum))
+		}
+		return
+	}
+
+	allowed := map[string]bool{}
+	for _, v := range readerEnum {
+		allowed[valueKey(v)] = true
+	}
+	missing := []interface{}{}
+	for _, v := range writerEnum {
+		if !allowed[valueKey(v)] {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if c.backward {
+		c.report(EnumNarrowed, EnumWidened, path, "enum values %s removed", formatValues(missing))
+	} else {
+		c.report(EnumNarrowed, EnumWidened, path, "enum values %s added", formatValues(missing))
+	}
+}
+
+func (c *checker) compareConst(reader, writer map[string]interface{}, path string) {
+	readerConst, ok := reader["const"]
+	if !ok {
+		return
+	}
+	if writerConst, ok := writer["const"]; ok && valueKey(writerConst) == valueKey(readerConst) {
+		return
+	}
+	if writerEnum, ok := writer["enum"].([]interface{}); ok {
+		accepted := true
+		for _, v := range writerEnum {
+			accepted = accepted && valueKey(v) == valueKey(readerConst)
+		}
+		if accepted {
+			return
+		}
+	}
+	previous, current := c.versions(reader["const"], writer["const"])
+	c.report(ConstraintTightened, ConstraintLoosened, path, "const changed from %s to %s", formatBound(previous), formatBound(current))
+}
+
+func (c *checker) compareBounds(reader, writer map[string]interface{}, path string) {
+	for _, key := range lowerBounds {
+		readerBound, ok := number(reader[key])
+		if !ok {
+			continue
+		}
+		writerBound, ok := number(writer[key])
+		if !ok || readerBound > writerBound {
+			c.reportBound(reader, writer, key, path)
+		}
+	}
+	for _, key := range upperBounds {
+		readerBound, ok := number(reader[key])
+		if !ok {
+			continue
+		}
+		writerBound, ok := number(writer[key])
+		if !ok || readerBound < writerBound {
+			c.reportBound(reader, writer, key, path)
+		}
+	}
+
+	// A writer multipleOf must be a multiple of the reader one
+	if readerFactor, ok := number(reader["multipleOf"]); ok && readerFactor > 0 {
+		writerFactor, ok := number(writer["multipleOf"])
+		if ratio := writerFactor / readerFactor; !ok || ratio != math.Trunc(ratio) {
+			c.reportBound(reader, writer, "multipleOf", path)
+		}
+	}
+	if reader["uniqueItems"] == true && writer["uniqueItems"] != true {
+		c.reportBound(reader, writer, "uniqueItems", path)
+	}
+	if readerFormat, ok := reader["format"].(string); ok {
+		if writerFormat, _ := writer["format"].(string); writerFormat != readerFormat {
+			c.reportBound(reader, writer, "format", path)
+		}
+	}
+
+	if readerPattern, ok := reader["pattern"].(string); ok {
+		if writerPattern, _ := writer["pattern"].(string); writerPattern != readerPattern {
+			previous, current := c.versions(reader["pattern"], writer["pattern"])
+			c.report(PatternChanged, PatternChanged, path, "pattern changed from %s to %s", formatBound(previous), formatBound(current))
+		}
+	}
+}
+
+func (c *checker) reportBound(reader, writer map[string]interface{}, key, path string) {
+	previous, current := c.versions(reader[key], writer[key])
+	c.report(ConstraintTightened, ConstraintLoosened, path, "%s changed from %s to %s", key, formatBound(previous), formatBound(current))
+}
+
+// compareAlternatives reports the branches of the anyOf or oneOf of writer,
+// or writer itself if it has none, that no branch of the anyOf or oneOf of
+// reader accepts. Branches are not checked for overlaps, so a document that
+// matches two branches of a oneOf passes.
+func (c *checker) compareAlternatives(reader, writer map[string]interface{}, path string) {
+	readerKeyword, readerBranches := alternatives(reader)
+	if readerBranches == nil {
+		return
+	}
+	writerKeyword, writerBranches := alternatives(writer)
+	if writerBranches == nil {
+		writerBranches = []map[string]interface{}{without(writer, readerKeyword)}
+	}
+	for i, writerBranch := range writerBranches {
+		accepted := false
+		for _, readerBranch := range readerBranches {
+			if c.accepts(readerBranch, writerBranch, path) {
+				accepted = true
+				break
+			}
+		}
+		if accepted {
+			continue
+		}
+		if writerKeyword == "" {
+			c.report(CompositionNarrowed, CompositionWidened, path+"/"+readerKeyword, "no branch of %s accepts the schema", readerKeyword)
+		} else if c.backward {
+			c.report(CompositionNarrowed, CompositionWidened, fmt.Sprintf("%s/%s/%d", path, writerKeyword, i), "branch %d of %s was removed", i, writerKeyword)
+		} else {
+			c.report(CompositionNarrowed, CompositionWidened, fmt.Sprintf("%s/%s/%d", path, writerKeyword, i), "branch %d of %s was added", i, writerKeyword)
+		}
+	}
+}
+
+// compareNot reports a reader not that rejects documents the writer not does
+// not reject.
+func (c *checker) compareNot(reader, writer map[string]interface{}, path string) {
+	readerNot, ok := reader["not"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if writerNot, ok := writer["not"].(map[string]interface{}); ok {
+		// Everything the reader rejects must be rejected by the writer
+		probe := newChecker(!c.backward, c.writerRoot, c.readerRoot)
+		probe.visiting = c.visiting
+		probe.compare(writerNot, readerNot, path+"/not")
+		if len(probe.violations) == 0 {
+			return
+		}
+	}
+	if c.backward {
+		c.report(CompositionNarrowed, CompositionWidened, path+"/not", "not was added or widened")
+	} else {
+		c.report(CompositionNarrowed, CompositionWidened, path+"/not", "not was removed or narrowed")
+	}
+}
+
+// accepts reports whether reader accepts every document valid under writer.
+func (c *checker) accepts(reader, writer map[string]interface{}, path string) bool {
+	probe := newChecker(c.backward, c.readerRoot, c.writerRoot)
+	probe.visiting = c.visiting
+	probe.compare(reader, writer, path)
+	return len(probe.violations) == 0
+}
+
+func (c *checker) compareObjects(reader, writer map[string]interface{}, path string) {
+	readerProps, _ := reader["properties"].(map[string]interface{})
+	writerProps, _ := writer["properties"].(map[string]interface{})
+	readerRequired := required(reader)
+	writerRequired := required(writer)
+
+	for _, name := range sortedKeys(readerRequired) {
+		if writerRequired[name] {
+			continue
+		}
+		propPath := path + "/properties/" + escapePointer(name)
+		if c.backward {
+			c.report(RequiredPropertyAdded, RequiredPropertyRemoved, propPath, "property %q is now required", name)
+		} else if _, ok := writerProps[name]; ok {
+			c.report(RequiredPropertyAdded, RequiredPropertyRemoved, propPath, "property %q is no longer required", name)
+		} else {
+			c.report(PropertyRemoved, PropertyRemoved, propPath, "required property %q was removed", name)
+		}
+	}
+
+	readerAdditional := reader["additionalProperties"]
+	closed := readerAdditional == false
+	writerAdditional := writer["additionalProperties"]
+	for _, name := range sortedKeys(readerProps) {
+		if _, ok := writerProps[name]; ok || writerAdditional == false {
+			continue
+		}
+		// The writer allows the property as an additional property
+		readerProp, _ := readerProps[name].(map[string]interface{})
+		propPath := path + "/properties/" + escapePointer(name)
+		if additional, ok := writerAdditional.(map[string]interface{}); ok {
+			c.compare(readerProp, additional, propPath)
+		} else if constrains(resolveSchema(c.readerRoot, readerProp)) {
+			if c.backward {
+				c.report(PropertyAdded, PropertyRemoved, propPath, "property %q was added but the previous version allows any value for it", name)
+			} else {
+				c.report(PropertyAdded, PropertyRemoved, propPath, "property %q was removed and is now allowed with any value", name)
+			}
+		}
+	}
+	for _, name := range sortedKeys(writerProps) {
+		writerProp, _ := writerProps[name].(map[string]interface{})
+		propPath := path + "/properties/" + escapePointer(name)
+		if readerProp, ok := readerProps[name].(map[string]interface{}); ok {
+			c.compare(readerProp, writerProp, propPath)
+			continue
+		}
+		if closed {
+			if c.backward {
+				c.report(PropertyRemoved, PropertyAdded, propPath, "property %q was removed and additional properties are not allowed", name)
+			} else {
+				c.report(PropertyRemoved, PropertyAdded, propPath, "property %q was added but the previous version does not allow additional properties", name)
+			}
+		} else if additional, ok := readerAdditional.(map[string]interface{}); ok {
+			c.compare(additional, writerProp, propPath)
+		}
+	}
+
+	switch writerAdditional := writerAdditional.(type) {
+	case map[string]interface{}:
+		if closed {
+			c.report(AdditionalPropertiesNarrowed, AdditionalPropertiesWidened, path+"/additionalProperties", "additional properties are no longer allowed")
+		} else if additional, ok := readerAdditional.(map[string]interface{}); ok {
+			c.compare(additional, writerAdditional, path+"/additionalProperties")
+		}
+	case bool:
+		if writerAdditional && closed {
+			c.report(AdditionalPropertiesNarrowed, AdditionalPropertiesWidened, path+"/additionalProperties", "additional properties are no longer allowed")
+		}
+	default:
+		if closed {
+			c.report(AdditionalPropertiesNarrowed, AdditionalPropertiesWidened, path+"/additionalProperties", "additional properties are no longer allowed")
+		}
+	}
+}
+
+// resolveRef follows local $refs from schema and returns the schema they
+// point to along with the last $ref followed. Unresolvable references yield an
+// empty schema, which accepts anything.
+func resolveRef(root, schema map[string]interface{}) (map[string]interface{}, string) {
+	last := ""
+	for i := 0; i < 32; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return schema, last
+		}
+		last = ref
+		target, ok := lookupPointer(root, strings.TrimPrefix(ref, "#")).(map[string]interface{})
+		if !ok {
+			return map[string]interface{}{}, last
+		}
+		schema = target
+	}
+	return map[string]interface{}{}, last
+}
+
+func resolveSchema(root, schema map[string]interface{}) map[string]interface{} {
+	schema, _ = resolveRef(root, schema)
+	return schema
+}
+
+// mergeAllOf returns schema with the branches of its allOf merged into it, or
+// schema itself if it has none.
+func mergeAllOf(root, schema map[string]interface{}) map[string]interface{} {
+	branches, ok := schema["allOf"].([]interface{})
+	if !ok {
+		return schema
+	}
+	merged := without(schema, "allOf")
+	for _, branch := range branches {
+		if branch, ok := branch.(map[string]interface{}); ok {
+			merged = merge(merged, mergeAllOf(root, resolveSchema(root, branch)))
+		}
+	}
+	return merged
+}
+
+// alternatives returns the branches of the anyOf or oneOf of schema and the
+// keyword holding them, or nil if it has neither.
+func alternatives(schema map[string]interface{}) (string, []map[string]interface{}) {
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		list, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		branches := []map[string]interface{}{}
+		for _, branch := range list {
+			if branch, ok := branch.(map[string]interface{}); ok {
+				branches = append(branches, branch)
+			}
+		}
+		return keyword, branches
+	}
+	return "", nil
+}
+
+// merge returns a copy of base with the keywords of schema added. Properties
+// and required are combined, other keywords of schema replace those of base.
+func merge(base, schema map[string]interface{}) map[string]interface{} {
+	merged := without(base, "")
+	for key, value := range schema {
+		switch key {
+		case "properties":
+			properties := map[string]interface{}{}
+			if baseProps, ok := merged[key].(map[string]interface{}); ok {
+				for name, prop := range baseProps {
+					properties[name] = prop
+				}
+			}
+			if props, ok := value.(map[string]interface{}); ok {
+				for name, prop := range props {
+					properties[name] = prop
+				}
+			}
+			merged[key] = properties
+		case "required":
+			names := []interface{}{}
+			seen := map[string]bool{}
+			baseRequired, _ := merged[key].([]interface{})
+			list, _ := value.([]interface{})
+			for _, name := range append(append([]interface{}{}, baseRequired...), list...) {
+				if s, ok := name.(string); ok && !seen[s] {
+					seen[s] = true
+					names = append(names, s)
+				}
+			}
+			merged[key] = names
+		default:
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// without returns a copy of schema without keyword.
+func without(schema map[string]interface{}, keyword string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		if key != keyword {
+			copied[key] = value
+		}
+	}
+	return copied
+}
+
+// constrains reports whether schema rejects any document.
+func constrains(schema map[string]interface{}) bool {
+	for key := range schema {
+		if !annotationKeywords[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupPointer returns the value a JSON pointer refers to within doc.
+func lookupPointer(doc interface{}, pointer string) interface{} {
+	if pointer == "" {
+		return doc
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			doc = node[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			doc = node[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
+
+// types returns the JSON types a schema allows, or nil if it allows any type.
+func types(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func acceptsType(types []string, t string) bool {
+	for _, accepted := range types {
+		if accepted == t || (accepted == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func allowsType(schema map[string]interface{}, t string) bool {
+	types := types(schema)
+	return types == nil || acceptsType(types, t)
+}
+
+func required(schema map[string]interface{}) map[string]bool {
+	names := map[string]bool{}
+	list, _ := schema["required"].([]interface{})
+	for _, v := range list {
+		if name, ok := v.(string); ok {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]bool:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func valueKey(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func formatTypes(v interface{}) string {
+	types, _ := v.([]string)
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+func formatValues(values []interface{}) string {
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = valueKey(v)
+	}
+	return "[" + strings.Join(keys, ", ") + "]"
+}
+
+func formatBound(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	return valueKey(v)
+}
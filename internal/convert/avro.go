@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+type AvroSchema interface{}
+
+type PrimitiveType string
+
+const (
+	NullType    PrimitiveType = "null"
+	BooleanType PrimitiveType = "boolean"
+	IntType     PrimitiveType = "int"
+	LongType    PrimitiveType = "long"
+	FloatType   PrimitiveType = "float"
+	DoubleType  PrimitiveType = "double"
+	BytesType   PrimitiveType = "bytes"
+	StringType  PrimitiveType = "string"
+)
+
+// Logical types, see https://avro.apache.org/docs/1.11.1/specification/#logical-types
+const (
+	DecimalLogical         = "decimal"
+	UUIDLogical            = "uuid"
+	DateLogical            = "date"
+	TimeMillisLogical      = "time-millis"
+	TimestampMillisLogical = "timestamp-millis"
+	TimestampMicrosLogical = "timestamp-micros"
+)
+
+type LogicalType struct {
+	Type        PrimitiveType `json:"type"`
+	LogicalType string        `json:"logicalType"`
+	Precision   int           `json:"precision,omitempty"`
+	Scale       int           `json:"scale,omitempty"`
+}
+
+type RecordField struct {
+	Name         string      `json:"name"`
+	Type         AvroSchema  `json:"type"`
+	DefaultValue interface{} `json:"default,omitempty"`
+	Doc          string      `json:"doc,omitempty"`
+}
+
+type RecordType struct {
+	Type      string        `json:"type"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	Aliases   []string      `json:"aliases,omitempty"`
+	Doc       string        `json:"doc,omitempty"`
+	Fields    []RecordField `json:"fields"`
+}
+
+type EnumSymbol string
+
+type EnumType struct {
+	Type    string       `json:"type"`
+	Name    string       `json:"name"`
+	Doc     string       `json:"doc,omitempty"`
+	Symbols []EnumSymbol `json:"symbols"`
+	Default EnumSymbol   `json:"default,omitempty"`
+
+	// values maps the original JSON values to their sanitized symbols
+	values map[string]EnumSymbol
+}
+
+type ArrayType struct {
+	Type  string     `json:"type"`
+	Items AvroSchema `json:"items"`
+}
+
+type MapType struct {
+	Type  string     `json:"type"`
+	Items AvroSchema `json:"values"`
+}
+
+type UnionType struct {
+	Types []AvroSchema `json:"type"`
+}
+
+// MarshalJSON writes the union as a plain JSON array, as Avro expects.
+func (u *UnionType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.Types)
+}
+
+type FixedType struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Size      int    `json:"size"`
+}
+
+type NameType struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type AliasesType struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// MarshalAvro returns the JSON form of an Avro schema after checking that it
+// is a valid Avro schema.
+func MarshalAvro(schema AvroSchema) ([]byte, error) {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	_, err = goavro.NewCodec(string(schemaJSON))
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	return schemaJSON, nil
+}
@@ -0,0 +1,492 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Options control the conversion of a JSON Schema to Avro.
+type Options struct {
+	// Name is used for the top-level record when the schema has no title.
+	Name string
+	// Namespace is set on the top-level record.
+	Namespace string
+	// TimestampMicros maps "date-time" to timestamp-micros instead of
+	// timestamp-millis.
+	TimestampMicros bool
+}
+
+// converter holds the state of a single conversion.
+type converter struct {
+	opts Options
+	root *JSONSchema
+	// names counts the record names handed out so far, Avro requires them
+	// to be unique within a schema
+	names map[string]int
+	// refs maps every $ref converted to a named record to the full name of
+	// that record, so later references reuse it
+	refs map[string]string
+	// resolving holds the $refs to non-object schemas being converted, to
+	// detect recursion that Avro cannot express
+	resolving map[string]bool
+	warnings  []string
+	err       error
+}
+
+// JSONSchemaToAvro converts a JSON Schema document to an Avro schema. The
+// returned warnings describe parts of the schema that Avro cannot express
+// exactly and were approximated.
+func JSONSchemaToAvro(schemaJSON []byte, opts Options) (AvroSchema, []string, error) {
+	schema := &JSONSchema{}
+	err := json.Unmarshal(schemaJSON, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &converter{
+		opts:      opts,
+		root:      schema,
+		names:     map[string]int{},
+		refs:      map[string]string{},
+		resolving: map[string]bool{},
+	}
+
+	var avroSchema AvroSchema
+	if schema.Ref == "" && len(schema.AllOf) > 0 {
+		schema = c.mergeAllOf(schema)
+	}
+	if schema.Ref == "" && isRecord(schema) {
+		// Register the root record so "#" references can point back to it
+		name := c.uniqueName(defaultRecordName(schema, opts.Name))
+		c.refs["#"] = c.fullName(name)
+		record := c.walkRecord(schema, name)
+		record.Namespace = opts.Namespace
+		avroSchema = record
+	} else {
+		avroSchema = c.walkSchema(schema, opts.Name)
+	}
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	return avroSchema, c.warnings, nil
+}
+
+func (c *converter) warn(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// fail records the first error of the conversion.
+func (c *converter) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *converter) walkSchema(schema *JSONSchema, recordName string) AvroSchema {
+	if schema.Ref != "" {
+		return c.walkRef(schema.Ref)
+	}
+	if schema.RecursiveRef != "" || schema.DynamicRef != "" {
+		return c.walkDynamicRef(schema)
+	}
+	if len(schema.AllOf) > 0 {
+		return c.walkSchema(c.mergeAllOf(schema), recordName)
+	}
+	if len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 {
+		return c.walkComposition(schema, recordName)
+	}
+	if len(schema.Enum) > 0 {
+		return c.walkEnumSchema(schema, recordName)
+	}
+	if schema.AvroDecimal != nil {
+		return c.walkDecimal(schema, recordName)
+	}
+	if len(schema.Type) > 1 {
+		return c.walkTypeUnion(schema, recordName)
+	}
+	switch schema.Type.Name() {
+	case "null":
+		return NullType
+	case "boolean":
+		return BooleanType
+	case "integer":
+		if schema.Format == "int64" {
+			return LongType
+		} else {
+			return IntType
+		}
+	case "number":
+		if schema.Format == "double" {
+			return DoubleType
+		} else {
+			return FloatType
+		}
+	case "string":
+		return c.walkStringFormat(schema)
+	case "array":
+		return c.walkArraySchema(schema, recordName)
+	case "object":
+		if isRecord(schema) {
+			return c.walkObjectSchema(schema, recordName)
+		}
+		return c.walkMapSchema(schema, recordName)
+	default:
+		// Avro has no "any" type, such values are carried as JSON encoded strings
+		return StringType
+	}
+}
+
+// walkArraySchema converts an array to an Avro array. Avro arrays have a
+// single item type, so the items of tuples become a union.
+func (c *converter) walkArraySchema(schema *JSONSchema, recordName string) AvroSchema {
+	items := &JSONSchema{}
+	if schema.Items != nil {
+		items = schema.Items
+	}
+	if len(schema.PrefixItems) == 0 {
+		return &ArrayType{
+			Type:  "array",
+			Items: c.walkSchema(items, recordName+"item"),
+		}
+	}
+
+	c.warn("tuple %s was converted to an array of the union of its item types", recordName)
+	branches := make([]AvroSchema, 0, len(schema.PrefixItems)+1)
+	for _, prefix := range schema.PrefixItems {
+		branches = append(branches, c.walkSchema(prefix, recordName+"item"))
+	}
+	if !items.never {
+		branches = append(branches, c.walkSchema(items, recordName+"item"))
+	}
+	return &ArrayType{
+		Type:  "array",
+		Items: c.union(branches, recordName+"item"),
+	}
+}
+
+// walkEnumSchema converts an enum of strings to an Avro enum. Avro enums can
+// only hold names, so other values fall back to a union of their types.
+func (c *converter) walkEnumSchema(schema *JSONSchema, recordName string) AvroSchema {
+	types := []AvroSchema{}
+	seen := map[AvroSchema]bool{}
+	for _, v := range schema.Enum {
+		t := valueType(v)
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	if len(types) > 1 || types[0] != StringType {
+		c.warn("enum %s has non-string values and was converted to %v", recordName, types)
+		return c.union(types, recordName)
+	}
+
+	name := schema.Title
+	if name == "" {
+		name = fmt.Sprintf("%senum", recordName)
+	}
+	enum := &EnumType{
+		Type:   "enum",
+		Name:   c.uniqueName(name),
+		Doc:    schema.Description,
+		values: map[string]EnumSymbol{},
+	}
+	var values []string
+	for _, v := range schema.Enum {
+		value := v.(string)
+		if _, ok := enum.values[value]; !ok {
+			enum.values[value] = ""
+			values = append(values, value)
+		}
+	}
+	for i, name := range sanitizeNames(values) {
+		value, symbol := values[i], EnumSymbol(name)
+		if name != value {
+			c.warn("enum %s value %q was renamed to symbol %s", enum.Name, value, symbol)
+		}
+		enum.values[value] = symbol
+		enum.Symbols = append(enum.Symbols, symbol)
+	}
+	if value, ok := schema.Default.(string); ok {
+		enum.Default = enum.values[value]
+	}
+	return enum
+}
+
+// valueType returns the Avro type of a JSON value.
+func valueType(v interface{}) AvroSchema {
+	switch v := v.(type) {
+	case nil:
+		return NullType
+	case bool:
+		return BooleanType
+	case float64:
+		if v == float64(int64(v)) {
+			return LongType
+		}
+		return DoubleType
+	case string:
+		return StringType
+	}
+	// Objects and arrays are carried as JSON encoded strings
+	return StringType
+}
+
+// walkRef converts the schema a local $ref points to. Objects are emitted as a
+// named record the first time and referenced by their full name afterwards,
+// which also covers recursive references.
+func (c *converter) walkRef(ref string) AvroSchema {
+	if name, ok := c.refs[ref]; ok {
+		return name
+	}
+	target, key, err := c.resolve(ref)
+	if err != nil {
+		c.fail(err)
+		return StringType
+	}
+
+	if target.Ref == "" && len(target.AllOf) > 0 {
+		target = c.mergeAllOf(target)
+	}
+	if target.Ref != "" || !isRecord(target) {
+		if c.resolving[ref] {
+			c.fail(fmt.Errorf("recursive $ref %s must point to an object", ref))
+			return StringType
+		}
+		c.resolving[ref] = true
+		defer delete(c.resolving, ref)
+		return c.walkSchema(target, key)
+	}
+
+	name := target.Title
+	if name == "" {
+		name = key
+	}
+	name = c.uniqueName(name)
+	c.refs[ref] = c.fullName(name)
+	return c.walkRecord(target, name)
+}
+
+// walkDynamicRef converts a $recursiveRef (2019-09) or $dynamicRef (2020-12).
+// Only references to the root schema, as "#" or its $dynamicAnchor, are
+// supported; they are converted like a $ref to "#".
+func (c *converter) walkDynamicRef(schema *JSONSchema) AvroSchema {
+	ref := schema.RecursiveRef
+	if ref == "" {
+		ref = schema.DynamicRef
+	}
+	if ref != "#" && (c.root.DynamicAnchor == "" || ref != "#"+c.root.DynamicAnchor) {
+		c.fail(fmt.Errorf("unsupported dynamic reference %s", ref))
+		return StringType
+	}
+	return c.walkRef("#")
+}
+
+// resolve looks up a local $ref and returns its target and the key it is
+// defined under. Only "#", "#/definitions/<key>" and "#/$defs/<key>" are
+// supported.
+func (c *converter) resolve(ref string) (*JSONSchema, string, error) {
+	var defs map[string]*JSONSchema
+	var key string
+	switch {
+	case ref == "#":
+		return c.root, c.opts.Name, nil
+	case strings.HasPrefix(ref, "#/definitions/"):
+		defs, key = c.root.Definitions, strings.TrimPrefix(ref, "#/definitions/")
+	case strings.HasPrefix(ref, "#/$defs/"):
+		defs, key = c.root.Defs, strings.TrimPrefix(ref, "#/$defs/")
+	default:
+		return nil, "", fmt.Errorf("unsupported $ref %s", ref)
+	}
+	key = strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
+	target, ok := defs[key]
+	if !ok || target == nil {
+		return nil, "", fmt.Errorf("unresolved $ref %s", ref)
+	}
+	return target, key, nil
+}
+
+func (c *converter) walkObjectSchema(schema *JSONSchema, recordName string) AvroSchema {
+	return c.walkRecord(schema, c.uniqueName(defaultRecordName(schema, recordName)))
+}
+
+// walkRecord converts an object schema to a record with the given name.
+func (c *converter) walkRecord(schema *JSONSchema, typeName string) *RecordType {
+	if len(schema.PatternProperties) > 0 || (schema.AdditionalProperties != nil &&
+		!reflect.DeepEqual(schema.AdditionalProperties, &JSONSchema{}) && !schema.AdditionalProperties.never) {
+		c.warn("record %s keeps only its declared properties, additional properties are dropped", typeName)
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fieldNames := sanitizeNames(names)
+
+	fields := make([]RecordField, 0, len(schema.Properties))
+	for i, name := range names {
+		prop := schema.Properties[name]
+		isRequired := false
+		for _, requiredProp := range schema.Required {
+			if requiredProp == name {
+				isRequired = true
+				break
+			}
+		}
+
+		var fieldType AvroSchema
+
+		if isRequired {
+			fieldType = c.walkSchema(prop, name)
+		} else {
+			fieldType = c.optional(c.walkSchema(prop, name), name)
+		}
+
+		field := RecordField{
+			Name: fieldNames[i],
+			Type: fieldType,
+		}
+		if prop.hasDefault {
+			if t, ok := defaultFirst(fieldType, prop.Default); !ok {
+				c.warn("default %v of %s does not match its type and was dropped", formatDefault(prop.Default), name)
+			} else if prop.Default == nil {
+				field.Type = t
+				field.DefaultValue = json.RawMessage("null")
+			} else {
+				field.Type = t
+				field.DefaultValue = c.fieldDefault(prop.Default, t, name)
+			}
+		}
+		if prop.Description != "" {
+			field.Doc = prop.Description
+		}
+		if field.Name != name {
+			// The original name is kept in the doc, it is not a valid
+			// Avro name and so cannot be an alias
+			c.warn("property %q of record %s was renamed to field %s", name, typeName, field.Name)
+			renamed := fmt.Sprintf("JSON property %q", name)
+			if field.Doc == "" {
+				field.Doc = renamed
+			} else {
+				field.Doc += " (" + renamed + ")"
+			}
+		}
+		fields = append(fields, field)
+	}
+	return &RecordType{
+		Type:   "record",
+		Name:   typeName,
+		Doc:    schema.Description,
+		Fields: fields,
+	}
+}
+
+// fieldDefault maps a JSON default to the Avro default of a field type. It
+// returns nil if the default cannot be expressed in Avro.
+func (c *converter) fieldDefault(value interface{}, fieldType AvroSchema, fieldName string) interface{} {
+	if union, ok := fieldType.(*UnionType); ok {
+		fieldType = union.Types[0]
+	}
+	switch t := fieldType.(type) {
+	case *EnumType:
+		if s, ok := value.(string); ok {
+			return t.values[s]
+		}
+	case *LogicalType:
+		if v, ok := logicalDefault(value, t); ok {
+			return v
+		}
+		c.warn("default %v of %s cannot be expressed as %s and was dropped", value, fieldName, t.LogicalType)
+		return nil
+	}
+	return value
+}
+
+// formatDefault renders a default for a warning.
+func formatDefault(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// defaultRecordName returns the title of an object schema, or a name derived
+// from the property it was found under.
+func defaultRecordName(schema *JSONSchema, propName string) string {
+	if schema.Title != "" {
+		return schema.Title
+	}
+	return fmt.Sprintf("%srecord", propName)
+}
+
+func (c *converter) fullName(name string) string {
+	if c.opts.Namespace == "" {
+		return name
+	}
+	return c.opts.Namespace + "." + name
+}
+
+// uniqueName sanitizes name and appends a counter if it was used before.
+func (c *converter) uniqueName(name string) string {
+	name = sanitizeName(name)
+	c.names[name]++
+	if n := c.names[name]; n > 1 {
+		return fmt.Sprintf("%s%d", name, n)
+	}
+	return name
+}
+
+// sanitizeNames returns distinct valid Avro names for names, which must be
+// distinct. Names that are already valid are kept; the others are sanitized
+// and get a "_2", "_3"... suffix when their sanitized name is taken.
+func sanitizeNames(names []string) []string {
+	taken := map[string]bool{}
+	for _, name := range names {
+		if sanitizeName(name) == name {
+			taken[name] = true
+		}
+	}
+	sanitized := make([]string, len(names))
+	for i, name := range names {
+		if sanitizeName(name) == name {
+			sanitized[i] = name
+			continue
+		}
+		base := sanitizeName(name)
+		candidate := base
+		for n := 2; taken[candidate]; n++ {
+			candidate = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[candidate] = true
+		sanitized[i] = candidate
+	}
+	return sanitized
+}
+
+// sanitizeName turns s into a valid Avro name by replacing every character
+// outside [A-Za-z0-9_] with an underscore.
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
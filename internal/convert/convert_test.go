@@ -0,0 +1,332 @@
+package convert
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// assertJSON fails unless got and want hold the same JSON value.
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestJSONSchemaToAvro(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		schema   string
+		want     string
+		warnings int
+	}{
+		{
+			name: "record",
+			schema: `{"title": "Order", "type": "object", "properties": {
+				"id": {"type": "string", "description": "order id"},
+				"count": {"type": "integer"},
+				"open": {"type": "boolean"}},
+				"required": ["id", "count"], "additionalProperties": false}`,
+			want: `{"type": "record", "name": "Order", "fields": [
+				{"name": "count", "type": "int"},
+				{"name": "id", "type": "string", "doc": "order id"},
+				{"name": "open", "type": ["boolean", "null"]}]}`,
+		},
+		{
+			name: "renamed fields",
+			schema: `{"title": "N", "type": "object", "properties": {
+				"a-b": {"type": "string", "description": "dashed"},
+				"a_b": {"type": "string"},
+				"a.b": {"type": "string"}},
+				"required": ["a-b", "a_b", "a.b"], "additionalProperties": false}`,
+			want: `{"type": "record", "name": "N", "fields": [
+				{"name": "a_b_2", "type": "string", "doc": "dashed (JSON property \"a-b\")"},
+				{"name": "a_b_3", "type": "string", "doc": "JSON property \"a.b\""},
+				{"name": "a_b", "type": "string"}]}`,
+			warnings: 2,
+		},
+		{
+			name:   "enum",
+			schema: `{"title": "E", "type": "object", "properties": {"color": {"enum": ["red", "dark-blue", "red"], "default": "dark-blue"}}, "required": ["color"]}`,
+			want: `{"type": "record", "name": "E", "fields": [
+				{"name": "color", "type": {"type": "enum", "name": "colorenum", "symbols": ["red", "dark_blue"], "default": "dark_blue"}, "default": "dark_blue"}]}`,
+			warnings: 1,
+		},
+		{
+			name:   "enum symbol clash",
+			schema: `{"title": "E", "type": "object", "properties": {"k": {"title": "Kind", "enum": ["a-b", "a_b", "a_b_2"]}}, "required": ["k"]}`,
+			want: `{"type": "record", "name": "E", "fields": [
+				{"name": "k", "type": {"type": "enum", "name": "Kind", "symbols": ["a_b_3", "a_b", "a_b_2"]}}]}`,
+			warnings: 1,
+		},
+		{
+			name:   "mixed enum",
+			schema: `{"title": "E", "type": "object", "properties": {"v": {"enum": ["a", 1, "b"]}}, "required": ["v"]}`,
+			want: `{"type": "record", "name": "E", "fields": [
+				{"name": "v", "type": ["string", "long"]}]}`,
+			warnings: 1,
+		},
+		{
+			name: "unions",
+			schema: `{"title": "U", "type": "object", "properties": {
+				"a": {"type": ["string", "integer"]},
+				"b": {"oneOf": [{"type": "string"}, {"type": "number"}]},
+				"c": {"type": "string"},
+				"d": {"anyOf": [{"type": "string"}, {"type": ["string", "null"]}]}},
+				"required": ["a", "b", "d"]}`,
+			want: `{"type": "record", "name": "U", "fields": [
+				{"name": "a", "type": ["string", "int"]},
+				{"name": "b", "type": ["string", "float"]},
+				{"name": "c", "type": ["string", "null"]},
+				{"name": "d", "type": ["string", "null"]}]}`,
+		},
+		{
+			name: "record union",
+			schema: `{"title": "Payment", "type": "object", "properties": {
+				"method": {"oneOf": [
+					{"title": "Card", "properties": {"number": {"type": "string"}}, "required": ["number"]},
+					{"title": "Iban", "properties": {"iban": {"type": "string"}}, "required": ["iban"]}],
+					"type": "object"}},
+				"required": ["method"]}`,
+			want: `{"type": "record", "name": "Payment", "fields": [
+				{"name": "method", "type": [
+					{"type": "record", "name": "Card", "fields": [{"name": "number", "type": "string"}]},
+					{"type": "record", "name": "Iban", "fields": [{"name": "iban", "type": "string"}]}]}]}`,
+		},
+		{
+			name: "allOf",
+			schema: `{"title": "A", "allOf": [
+				{"type": "object", "properties": {"x": {"type": "string"}}, "required": ["x"]},
+				{"properties": {"y": {"type": "boolean"}}, "required": ["y"]}]}`,
+			want: `{"type": "record", "name": "A", "fields": [
+				{"name": "x", "type": "string"},
+				{"name": "y", "type": "boolean"}]}`,
+		},
+		{
+			name: "logical types",
+			schema: `{"title": "L", "type": "object", "properties": {
+				"ts": {"type": "string", "format": "date-time"},
+				"d": {"type": "string", "format": "date"},
+				"t": {"type": "string", "format": "time"},
+				"id": {"type": "string", "format": "uuid"},
+				"mail": {"type": "string", "format": "email"},
+				"amount": {"type": "string", "x-avro-decimal": {"precision": 10, "scale": 2}}},
+				"required": ["ts", "d", "t", "id", "mail", "amount"]}`,
+			want: `{"type": "record", "name": "L", "fields": [
+				{"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+				{"name": "d", "type": {"type": "int", "logicalType": "date"}},
+				{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+				{"name": "mail", "type": "string"},
+				{"name": "t", "type": {"type": "int", "logicalType": "time-millis"}},
+				{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}}]}`,
+		},
+		{
+			name:   "timestamp micros",
+			opts:   Options{TimestampMicros: true},
+			schema: `{"title": "T", "type": "object", "properties": {"ts": {"type": "string", "format": "date-time"}}, "required": ["ts"]}`,
+			want: `{"type": "record", "name": "T", "fields": [
+				{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-micros"}}]}`,
+		},
+		{
+			name: "logical defaults",
+			schema: `{"title": "T", "type": "object", "properties": {
+				"ts": {"type": "string", "format": "date-time", "default": "1970-01-01T00:00:01Z"},
+				"d": {"type": "string", "format": "date", "default": "1970-01-11"},
+				"t": {"type": "string", "format": "time", "default": "00:01:00"},
+				"bad": {"type": "string", "format": "date", "default": "soon"}},
+				"required": ["ts", "d", "t", "bad"]}`,
+			want: `{"type": "record", "name": "T", "fields": [
+				{"name": "bad", "type": {"type": "int", "logicalType": "date"}},
+				{"name": "d", "type": {"type": "int", "logicalType": "date"}, "default": 10},
+				{"name": "t", "type": {"type": "int", "logicalType": "time-millis"}, "default": 60000},
+				{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}, "default": 1000}]}`,
+			warnings: 1,
+		},
+		{
+			name: "maps",
+			schema: `{"title": "M", "type": "object", "properties": {
+				"counts": {"type": "object", "additionalProperties": {"type": "integer"}},
+				"extra": {"type": "object"},
+				"labels": {"type": "object", "patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false},
+				"items": {"type": "object", "additionalProperties": {"title": "Item", "type": "object", "properties": {"n": {"type": "integer"}}, "required": ["n"]}}},
+				"required": ["counts", "extra", "labels", "items"]}`,
+			want: `{"type": "record", "name": "M", "fields": [
+				{"name": "counts", "type": {"type": "map", "values": "int"}},
+				{"name": "extra", "type": {"type": "map", "values": "string"}},
+				{"name": "items", "type": {"type": "map", "values": {"type": "record", "name": "Item", "fields": [{"name": "n", "type": "int"}]}}},
+				{"name": "labels", "type": {"type": "map", "values": "string"}}]}`,
+			warnings: 1,
+		},
+		{
+			name: "refs",
+			schema: `{"title": "R", "type": "object",
+				"properties": {"a": {"$ref": "#/definitions/P"}, "b": {"$ref": "#/definitions/P"}},
+				"required": ["a", "b"],
+				"definitions": {"P": {"type": "object", "properties": {"x": {"type": "integer"}}, "required": ["x"]}}}`,
+			want: `{"type": "record", "name": "R", "fields": [
+				{"name": "a", "type": {"type": "record", "name": "P", "fields": [{"name": "x", "type": "int"}]}},
+				{"name": "b", "type": "P"}]}`,
+		},
+		{
+			name: "recursive defs",
+			schema: `{"title": "Tree", "type": "object",
+				"properties": {"root": {"$ref": "#/$defs/Node"}, "size": {"$ref": "#/$defs/Count"}},
+				"required": ["root", "size"],
+				"$defs": {
+					"Count": {"type": "integer"},
+					"Node": {"type": "object", "properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/Node"}}}, "required": ["children"]}}}`,
+			want: `{"type": "record", "name": "Tree", "fields": [
+				{"name": "root", "type": {"type": "record", "name": "Node", "fields": [
+					{"name": "children", "type": {"type": "array", "items": "Node"}}]}},
+				{"name": "size", "type": "int"}]}`,
+		},
+		{
+			name: "draft-04",
+			schema: `{"$schema": "http://json-schema.org/draft-04/schema#", "title": "D", "type": "object", "properties": {
+				"n": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 9, "exclusiveMaximum": false},
+				"t": {"type": "array", "items": [{"type": "string"}, {"type": "integer"}], "additionalItems": false}},
+				"required": ["n", "t"]}`,
+			want: `{"type": "record", "name": "D", "fields": [
+				{"name": "n", "type": "float"},
+				{"name": "t", "type": {"type": "array", "items": ["string", "int"]}}]}`,
+			warnings: 1,
+		},
+		{
+			name: "draft 2020-12",
+			schema: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "$dynamicAnchor": "node", "title": "Node", "type": "object",
+				"properties": {
+					"children": {"type": "array", "items": {"$dynamicRef": "#node"}},
+					"pair": {"type": "array", "prefixItems": [{"type": "string"}], "items": {"type": "boolean"}},
+					"n": {"type": "integer", "exclusiveMinimum": 0}},
+				"required": ["children", "pair", "n"], "additionalProperties": false}`,
+			want: `{"type": "record", "name": "Node", "fields": [
+				{"name": "children", "type": {"type": "array", "items": "Node"}},
+				{"name": "n", "type": "int"},
+				{"name": "pair", "type": {"type": "array", "items": ["string", "boolean"]}}]}`,
+			warnings: 1,
+		},
+		{
+			name: "draft 2019-09",
+			schema: `{"$schema": "https://json-schema.org/draft/2019-09/schema", "$recursiveAnchor": true, "title": "Tree", "type": "object",
+				"properties": {"children": {"type": "array", "items": {"$recursiveRef": "#"}}},
+				"required": ["children"], "additionalProperties": false}`,
+			want: `{"type": "record", "name": "Tree", "fields": [
+				{"name": "children", "type": {"type": "array", "items": "Tree"}}]}`,
+		},
+		{
+			name:   "name from options",
+			opts:   Options{Name: "Fallback", Namespace: "com.example"},
+			schema: `{"type": "object", "properties": {"a": {"type": "boolean"}}, "required": ["a"]}`,
+			want: `{"type": "record", "name": "Fallbackrecord", "namespace": "com.example", "fields": [
+				{"name": "a", "type": "boolean"}]}`,
+		},
+		{
+			name: "defaults",
+			schema: `{"title": "D", "type": "object", "properties": {
+				"a": {"type": ["null", "string"], "default": "x"},
+				"b": {"type": "string", "default": null},
+				"c": {"type": "string", "default": null},
+				"d": {"type": "integer", "default": 3}},
+				"required": ["b", "d"]}`,
+			want: `{"type": "record", "name": "D", "fields": [
+				{"name": "a", "type": ["string", "null"], "default": "x"},
+				{"name": "b", "type": "string"},
+				{"name": "c", "type": ["null", "string"], "default": null},
+				{"name": "d", "type": "int", "default": 3}]}`,
+			warnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, warnings, err := JSONSchemaToAvro([]byte(tt.schema), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// MarshalAvro also checks that the result is a valid Avro schema
+			got, err := MarshalAvro(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+			if len(warnings) != tt.warnings {
+				t.Errorf("got warnings %q, want %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaToAvroErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "unresolved ref", schema: `{"type": "object", "properties": {"a": {"$ref": "#/definitions/Missing"}}}`},
+		{name: "remote ref", schema: `{"type": "object", "properties": {"a": {"$ref": "other.json"}}}`},
+		{name: "unknown dynamic anchor", schema: `{"type": "object", "properties": {"a": {"$dynamicRef": "#meta"}}}`},
+		{name: "invalid exclusiveMinimum", schema: `{"type": "object", "properties": {"a": {"exclusiveMinimum": "1"}}}`},
+		{name: "decimal without precision", schema: `{"type": "object", "properties": {"a": {"type": "string", "x-avro-decimal": {"scale": 2}}}}`},
+		{name: "recursive scalar ref", schema: `{"type": "object", "properties": {"a": {"$ref": "#/definitions/A"}}, "definitions": {"A": {"$ref": "#/definitions/A"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := JSONSchemaToAvro([]byte(tt.schema), Options{Name: "Root"}); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestJSONSchemaDraftKeywords(t *testing.T) {
+	one, two := 1.0, 2.0
+	tests := []struct {
+		name   string
+		schema string
+		want   JSONSchema
+	}{
+		{
+			name:   "draft-04 exclusive bounds",
+			schema: `{"minimum": 1, "exclusiveMinimum": true, "maximum": 2, "exclusiveMaximum": false}`,
+			want:   JSONSchema{ExclusiveMinimum: &one, Maximum: &two},
+		},
+		{
+			name:   "numeric exclusive bounds",
+			schema: `{"exclusiveMinimum": 1, "exclusiveMaximum": 2}`,
+			want:   JSONSchema{ExclusiveMinimum: &one, ExclusiveMaximum: &two},
+		},
+		{
+			name:   "items array",
+			schema: `{"items": [{"type": "string"}], "additionalItems": {"type": "integer"}}`,
+			want:   JSONSchema{PrefixItems: []*JSONSchema{{Type: SchemaType{"string"}}}, Items: &JSONSchema{Type: SchemaType{"integer"}}},
+		},
+		{
+			name:   "prefixItems",
+			schema: `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`,
+			want:   JSONSchema{PrefixItems: []*JSONSchema{{Type: SchemaType{"string"}}}, Items: &JSONSchema{Type: SchemaType{"integer"}}},
+		},
+		{
+			name:   "dynamic references",
+			schema: `{"$dynamicAnchor": "meta", "$dynamicRef": "#meta", "$recursiveRef": "#", "$vocabulary": {"https://example.com/v": true}}`,
+			want:   JSONSchema{DynamicAnchor: "meta", DynamicRef: "#meta", RecursiveRef: "#", Vocabulary: map[string]bool{"https://example.com/v": true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got JSONSchema
+			if err := json.Unmarshal([]byte(tt.schema), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
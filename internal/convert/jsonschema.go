@@ -0,0 +1,184 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSONSchema is the subset of JSON Schema keywords the converter understands.
+type JSONSchema struct {
+	Type  SchemaType  `json:"type,omitempty"`
+	Items *JSONSchema `json:"items,omitempty"`
+	// PrefixItems holds the schemas of the leading items of tuples, from
+	// "prefixItems" (2020-12) or an array given as "items" (before 2020-12)
+	PrefixItems          []*JSONSchema          `json:"prefixItems,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	PatternProperties    map[string]*JSONSchema `json:"patternProperties,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MultipleOf           *float64               `json:"multipleOf,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	UniqueItems          bool                   `json:"uniqueItems,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	Not                  *JSONSchema            `json:"not,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	AdditionalItems      *JSONSchema            `json:"additionalItems,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	Examples             []interface{}          `json:"examples,omitempty"`
+	If                   *JSONSchema            `json:"if,omitempty"`
+	Then                 *JSONSchema            `json:"then,omitempty"`
+	Else                 *JSONSchema            `json:"else,omitempty"`
+	DependentSchemas     map[string]*JSONSchema `json:"dependentSchemas,omitempty"`
+	DependentRequired    map[string][]string    `json:"dependentRequired,omitempty"`
+	Contains             *JSONSchema            `json:"contains,omitempty"`
+	PropertyNames        *JSONSchema            `json:"propertyNames,omitempty"`
+	Anchor               string                 `json:"$anchor,omitempty"`
+	Merge                bool                   `json:"$merge,omitempty"`
+	RecursiveRef         string                 `json:"$recursiveRef,omitempty"`
+	RecursiveAnchor      bool                   `json:"$recursiveAnchor,omitempty"`
+	DynamicRef           string                 `json:"$dynamicRef,omitempty"`
+	DynamicAnchor        string                 `json:"$dynamicAnchor,omitempty"`
+	Vocabulary           map[string]bool        `json:"$vocabulary,omitempty"`
+	Fluent               bool                   `json:"$fluent,omitempty"`
+	Comment              string                 `json:"$comment,omitempty"`
+	RefScope             string                 `json:"$refScope,omitempty"`
+	Extension            map[string]interface{} `json:"-"`
+	AvroDecimal          *AvroDecimal           `json:"x-avro-decimal,omitempty"`
+
+	// hasDefault tells an explicit "default": null from a missing default
+	hasDefault bool
+	// never is set for the schema false, which no value matches
+	never bool
+}
+
+func (s *JSONSchema) UnmarshalJSON(b []byte) error {
+	// Since draft-06 true and false are schemas as well
+	var boolean bool
+	if err := json.Unmarshal(b, &boolean); err == nil {
+		*s = JSONSchema{never: !boolean}
+		return nil
+	}
+
+	// The keywords whose form changed between drafts are decoded by hand,
+	// their form tells the drafts apart
+	type plain JSONSchema
+	schema := struct {
+		*plain
+		Items            json.RawMessage `json:"items,omitempty"`
+		ExclusiveMaximum json.RawMessage `json:"exclusiveMaximum,omitempty"`
+		ExclusiveMinimum json.RawMessage `json:"exclusiveMinimum,omitempty"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return err
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keywords); err != nil {
+		return err
+	}
+	_, s.hasDefault = keywords["default"]
+
+	if len(schema.Items) > 0 {
+		if err := s.unmarshalItems(schema.Items); err != nil {
+			return err
+		}
+	}
+	var err error
+	if s.ExclusiveMaximum, s.Maximum, err = exclusiveBound(schema.ExclusiveMaximum, s.Maximum); err != nil {
+		return fmt.Errorf("exclusiveMaximum: %w", err)
+	}
+	if s.ExclusiveMinimum, s.Minimum, err = exclusiveBound(schema.ExclusiveMinimum, s.Minimum); err != nil {
+		return fmt.Errorf("exclusiveMinimum: %w", err)
+	}
+	return nil
+}
+
+// unmarshalItems decodes "items", a schema or, before 2020-12, an array of
+// schemas for the leading items, followed by "additionalItems" for the rest.
+func (s *JSONSchema) unmarshalItems(b json.RawMessage) error {
+	var prefix []*JSONSchema
+	if err := json.Unmarshal(b, &prefix); err != nil {
+		s.Items = &JSONSchema{}
+		return json.Unmarshal(b, s.Items)
+	}
+	s.PrefixItems, s.Items, s.AdditionalItems = prefix, s.AdditionalItems, nil
+	return nil
+}
+
+// exclusiveBound decodes exclusiveMaximum or exclusiveMinimum, a number since
+// draft-06. In draft-04 it is a boolean that makes the maximum or minimum
+// bound exclusive, which is returned as the exclusive bound instead.
+func exclusiveBound(b json.RawMessage, bound *float64) (*float64, *float64, error) {
+	if len(b) == 0 {
+		return nil, bound, nil
+	}
+	var exclusive bool
+	if err := json.Unmarshal(b, &exclusive); err == nil {
+		if exclusive {
+			return bound, nil, nil
+		}
+		return nil, bound, nil
+	}
+	var value float64
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, nil, fmt.Errorf("must be a number or, in draft-04, a boolean")
+	}
+	return &value, bound, nil
+}
+
+// AvroDecimal is the "x-avro-decimal" annotation, which maps a value to the
+// Avro decimal logical type.
+type AvroDecimal struct {
+	Precision int `json:"precision"`
+	Scale     int `json:"scale,omitempty"`
+}
+
+// SchemaType holds the "type" keyword, which is either a single type name or
+// an array of them.
+type SchemaType []string
+
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = SchemaType{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = names
+	return nil
+}
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Name returns the type name if exactly one type is given.
+func (t SchemaType) Name() string {
+	if len(t) == 1 {
+		return t[0]
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"fmt"
+	"time"
+)
+
+// walkStringFormat maps the string formats that have an Avro logical type.
+func (c *converter) walkStringFormat(schema *JSONSchema) AvroSchema {
+	switch schema.Format {
+	case "date-time":
+		if c.opts.TimestampMicros {
+			return &LogicalType{Type: LongType, LogicalType: TimestampMicrosLogical}
+		}
+		return &LogicalType{Type: LongType, LogicalType: TimestampMillisLogical}
+	case "date":
+		return &LogicalType{Type: IntType, LogicalType: DateLogical}
+	case "time":
+		return &LogicalType{Type: IntType, LogicalType: TimeMillisLogical}
+	case "uuid":
+		return &LogicalType{Type: StringType, LogicalType: UUIDLogical}
+	}
+	return StringType
+}
+
+func (c *converter) walkDecimal(schema *JSONSchema, recordName string) AvroSchema {
+	decimal := schema.AvroDecimal
+	if decimal.Precision < 1 || decimal.Scale < 0 || decimal.Scale > decimal.Precision {
+		c.fail(fmt.Errorf("x-avro-decimal of %s needs a precision of at least 1 and a scale between 0 and the precision", recordName))
+		return BytesType
+	}
+	return &LogicalType{
+		Type:        BytesType,
+		LogicalType: DecimalLogical,
+		Precision:   decimal.Precision,
+		Scale:       decimal.Scale,
+	}
+}
+
+// logicalDefault converts a JSON default to the underlying value of a logical
+// type.
+func logicalDefault(value interface{}, t *LogicalType) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	switch t.LogicalType {
+	case UUIDLogical:
+		return s, true
+	case TimestampMillisLogical, TimestampMicrosLogical:
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false
+		}
+		if t.LogicalType == TimestampMicrosLogical {
+			return ts.UnixNano() / int64(time.Microsecond), true
+		}
+		return ts.UnixNano() / int64(time.Millisecond), true
+	case DateLogical:
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, false
+		}
+		return d.Unix() / (24 * 60 * 60), true
+	case TimeMillisLogical:
+		for _, layout := range []string{"15:04:05.999999999Z07:00", "15:04:05.999999999"} {
+			if tm, err := time.Parse(layout, s); err == nil {
+				midnight := time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, tm.Location())
+				return tm.Sub(midnight).Milliseconds(), true
+			}
+		}
+	}
+	return nil, false
+}
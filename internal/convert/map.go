@@ -0,0 +1,47 @@
+package convert
+
+import "sort"
+
+// isRecord reports whether an object schema is converted to a record. Objects
+// that declare no properties and allow additional ones are dictionaries and
+// become maps instead.
+func isRecord(schema *JSONSchema) bool {
+	if schema.Type.Name() != "object" || len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 {
+		return false
+	}
+	if len(schema.Properties) > 0 {
+		return true
+	}
+	return schema.AdditionalProperties != nil && schema.AdditionalProperties.never && len(schema.PatternProperties) == 0
+}
+
+// walkMapSchema converts a dictionary-style object to an Avro map whose
+// values are the additional and pattern properties. Avro map keys cannot be
+// restricted, so key patterns are lost.
+func (c *converter) walkMapSchema(schema *JSONSchema, recordName string) AvroSchema {
+	values := []AvroSchema{}
+	if len(schema.PatternProperties) > 0 {
+		c.warn("patternProperties of %s were converted to a map, the key patterns are not enforced", recordName)
+		patterns := make([]string, 0, len(schema.PatternProperties))
+		for pattern := range schema.PatternProperties {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			values = append(values, c.walkSchema(schema.PatternProperties[pattern], recordName+"value"))
+		}
+	}
+
+	additional := schema.AdditionalProperties
+	if additional == nil {
+		additional = &JSONSchema{}
+	}
+	if !additional.never {
+		values = append(values, c.walkSchema(additional, recordName+"value"))
+	}
+
+	return &MapType{
+		Type:  "map",
+		Items: c.union(values, recordName),
+	}
+}
@@ -0,0 +1,329 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// JSONSchemaDraft is the meta-schema declared by converted Avro schemas.
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// reverser holds the state of a single Avro to JSON Schema conversion.
+type reverser struct {
+	// refs counts the references to every named type by name, named types
+	// referenced at all are moved to "definitions"
+	refs        map[string]int
+	root        string
+	definitions map[string]interface{}
+}
+
+// AvroToJSONSchema converts an Avro schema to a JSON Schema document. Named
+// types that are referenced more than once are placed in "definitions".
+func AvroToJSONSchema(avroJSON []byte) (map[string]interface{}, error) {
+	if _, err := goavro.NewCodec(string(avroJSON)); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	var avroSchema interface{}
+	if err := json.Unmarshal(avroJSON, &avroSchema); err != nil {
+		return nil, err
+	}
+
+	r := &reverser{
+		refs:        map[string]int{},
+		definitions: map[string]interface{}{},
+	}
+	r.collect(avroSchema, "")
+	if node, ok := avroSchema.(map[string]interface{}); ok {
+		if name, ok := node["name"].(string); ok {
+			r.root = fullName(name, node, "")
+		}
+	}
+
+	schema := r.convert(avroSchema, "")
+	if r.root != "" && r.refs[r.root] > 0 {
+		// The root is referenced by "#" and is not repeated in definitions
+		delete(r.definitions, r.root)
+	}
+	if len(r.definitions) > 0 {
+		schema["definitions"] = r.definitions
+	}
+	schema["$schema"] = JSONSchemaDraft
+	return schema, nil
+}
+
+// collect registers every named type and counts the references to it.
+func (r *reverser) collect(node interface{}, namespace string) {
+	switch node := node.(type) {
+	case string:
+		if !isPrimitive(node) {
+			r.refs[qualify(node, namespace)]++
+		}
+	case []interface{}:
+		for _, branch := range node {
+			r.collect(branch, namespace)
+		}
+	case map[string]interface{}:
+		switch t := node["type"].(type) {
+		case string:
+			switch t {
+			case "record", "error", "enum", "fixed":
+				name := fullName(node["name"].(string), node, namespace)
+				for _, field := range fields(node) {
+					r.collect(field["type"], namespaceOf(name))
+				}
+			case "array":
+				r.collect(node["items"], namespace)
+			case "map":
+				r.collect(node["values"], namespace)
+			}
+		default:
+			r.collect(t, namespace)
+		}
+	}
+}
+
+// convert returns the JSON Schema for an Avro schema node.
+func (r *reverser) convert(node interface{}, namespace string) map[string]interface{} {
+	switch node := node.(type) {
+	case string:
+		if isPrimitive(node) {
+			return primitiveSchema(node)
+		}
+		return r.ref(qualify(node, namespace))
+	case []interface{}:
+		schema, nullable := r.convertUnion(node, namespace)
+		if nullable {
+			return nullableSchema(schema)
+		}
+		return schema
+	case map[string]interface{}:
+		t, ok := node["type"].(string)
+		if !ok {
+			return r.convert(node["type"], namespace)
+		}
+		if logical, ok := node["logicalType"].(string); ok {
+			if schema := logicalSchema(logical, node); schema != nil {
+				return schema
+			}
+		}
+		switch t {
+		case "record", "error", "enum", "fixed":
+			name := fullName(node["name"].(string), node, namespace)
+			if r.refs[name] == 0 {
+				return r.convertNamed(node, name)
+			}
+			if _, ok := r.definitions[name]; !ok {
+				r.definitions[name] = r.convertNamed(node, name)
+			}
+			if name == r.root {
+				return r.definitions[name].(map[string]interface{})
+			}
+			return r.ref(name)
+		case "array":
+			return map[string]interface{}{
+				"type":  "array",
+				"items": r.convert(node["items"], namespace),
+			}
+		case "map":
+			return map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": r.convert(node["values"], namespace),
+			}
+		}
+		return primitiveSchema(t)
+	}
+	return map[string]interface{}{}
+}
+
+func (r *reverser) convertNamed(node map[string]interface{}, name string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"title": node["name"],
+	}
+	if doc, ok := node["doc"].(string); ok {
+		schema["description"] = doc
+	}
+
+	switch node["type"] {
+	case "enum":
+		schema["type"] = "string"
+		schema["enum"] = node["symbols"]
+		if def, ok := node["default"]; ok {
+			schema["default"] = def
+		}
+	case "fixed":
+		schema["type"] = "string"
+	default:
+		schema["type"] = "object"
+		properties := map[string]interface{}{}
+		required := []interface{}{}
+		for _, field := range fields(node) {
+			fieldName := field["name"].(string)
+			var prop map[string]interface{}
+			nullable := false
+			if union, ok := field["type"].([]interface{}); ok {
+				prop, nullable = r.convertUnion(union, namespaceOf(name))
+				if nullable {
+					// Optional, and null when present too, as with a
+					// null default
+					prop = nullableSchema(prop)
+				}
+			} else {
+				prop = r.convert(field["type"], namespaceOf(name))
+			}
+			if doc, ok := field["doc"].(string); ok {
+				prop = withKeyword(prop, "description", doc)
+			}
+			if def, ok := field["default"]; ok && (def != nil || nullable) {
+				prop = withKeyword(prop, "default", def)
+			}
+			if !nullable {
+				required = append(required, fieldName)
+			}
+			properties[fieldName] = prop
+		}
+		schema["properties"] = properties
+		// Avro records hold their fields only
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	return schema
+}
+
+// convertUnion converts the non-null branches of a union and reports whether
+// the union includes null.
+func (r *reverser) convertUnion(union []interface{}, namespace string) (map[string]interface{}, bool) {
+	nullable := false
+	branches := []interface{}{}
+	for _, branch := range union {
+		if branch == "null" {
+			nullable = true
+			continue
+		}
+		branches = append(branches, r.convert(branch, namespace))
+	}
+	switch len(branches) {
+	case 0:
+		return map[string]interface{}{"type": "null"}, false
+	case 1:
+		return branches[0].(map[string]interface{}), nullable
+	}
+	return map[string]interface{}{"oneOf": branches}, nullable
+}
+
+func (r *reverser) ref(name string) map[string]interface{} {
+	if name == r.root {
+		return map[string]interface{}{"$ref": "#"}
+	}
+	return map[string]interface{}{"$ref": "#/definitions/" + name}
+}
+
+// nullableSchema allows null next to schema.
+func nullableSchema(schema map[string]interface{}) map[string]interface{} {
+	if t, ok := schema["type"].(string); ok && schema["$ref"] == nil {
+		return withKeyword(schema, "type", []interface{}{t, "null"})
+	}
+	if branches, ok := schema["oneOf"].([]interface{}); ok {
+		nullable := append([]interface{}{map[string]interface{}{"type": "null"}}, branches...)
+		return withKeyword(schema, "oneOf", nullable)
+	}
+	return map[string]interface{}{
+		"oneOf": []interface{}{map[string]interface{}{"type": "null"}, schema},
+	}
+}
+
+// withKeyword returns a copy of schema with key set, leaving shared
+// definitions and references untouched.
+func withKeyword(schema map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+func primitiveSchema(t string) map[string]interface{} {
+	switch t {
+	case "null", "boolean", "string":
+		return map[string]interface{}{"type": t}
+	case "int":
+		return map[string]interface{}{"type": "integer"}
+	case "long":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "float":
+		return map[string]interface{}{"type": "number"}
+	case "double":
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case "bytes":
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	}
+	return map[string]interface{}{}
+}
+
+// logicalSchema maps an Avro logical type to a string format, or returns nil
+// for logical types without a JSON Schema counterpart.
+func logicalSchema(logical string, node map[string]interface{}) map[string]interface{} {
+	switch logical {
+	case TimestampMillisLogical, TimestampMicrosLogical:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case DateLogical:
+		return map[string]interface{}{"type": "string", "format": "date"}
+	case TimeMillisLogical, "time-micros":
+		return map[string]interface{}{"type": "string", "format": "time"}
+	case UUIDLogical:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case DecimalLogical:
+		decimal := map[string]interface{}{"precision": node["precision"]}
+		if scale, ok := node["scale"]; ok {
+			decimal["scale"] = scale
+		}
+		return map[string]interface{}{"type": "string", "x-avro-decimal": decimal}
+	}
+	return nil
+}
+
+func isPrimitive(t string) bool {
+	switch t {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	}
+	return false
+}
+
+func fields(node map[string]interface{}) []map[string]interface{} {
+	list, _ := node["fields"].([]interface{})
+	fields := make([]map[string]interface{}, 0, len(list))
+	for _, field := range list {
+		if field, ok := field.(map[string]interface{}); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// fullName returns the full name of a named type declared in namespace.
+func fullName(name string, node map[string]interface{}, namespace string) string {
+	if ns, ok := node["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	return qualify(name, namespace)
+}
+
+func qualify(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func namespaceOf(fullName string) string {
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAvroToJSONSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		avro string
+		want string
+	}{
+		{
+			name: "nullable field",
+			avro: `{"type": "record", "name": "R", "fields": [{"name": "a", "type": ["null", "string"], "default": null}]}`,
+			want: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "R", "type": "object", "additionalProperties": false,
+				"properties": {"a": {"type": ["string", "null"], "default": null}}}`,
+		},
+		{
+			name: "nullable union",
+			avro: `{"type": "record", "name": "R", "fields": [{"name": "a", "type": ["null", "string", "int"], "default": null}]}`,
+			want: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "R", "type": "object", "additionalProperties": false,
+				"properties": {"a": {"oneOf": [{"type": "null"}, {"type": "string"}, {"type": "integer"}], "default": null}}}`,
+		},
+		{
+			name: "enum, map and logical type",
+			avro: `{"type": "record", "name": "R", "fields": [
+				{"name": "e", "type": {"type": "enum", "name": "E", "symbols": ["A", "B"]}},
+				{"name": "m", "type": {"type": "map", "values": "long"}},
+				{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}}]}`,
+			want: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "R", "type": "object", "additionalProperties": false,
+				"properties": {
+					"e": {"title": "E", "type": "string", "enum": ["A", "B"]},
+					"m": {"type": "object", "additionalProperties": {"type": "integer", "format": "int64"}},
+					"ts": {"type": "string", "format": "date-time"}},
+				"required": ["e", "m", "ts"]}`,
+		},
+		{
+			name: "named type used twice",
+			avro: `{"type": "record", "name": "R", "fields": [
+				{"name": "p", "type": {"type": "record", "name": "P", "fields": [{"name": "x", "type": "int"}]}},
+				{"name": "q", "type": "P"}]}`,
+			want: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "R", "type": "object", "additionalProperties": false,
+				"properties": {"p": {"$ref": "#/definitions/P"}, "q": {"$ref": "#/definitions/P"}},
+				"required": ["p", "q"],
+				"definitions": {"P": {"title": "P", "type": "object", "additionalProperties": false, "properties": {"x": {"type": "integer"}}, "required": ["x"]}}}`,
+		},
+		{
+			name: "doc",
+			avro: `{"type": "record", "name": "R", "doc": "a record", "fields": [{"name": "a", "type": "string", "doc": "a field"}]}`,
+			want: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "R", "type": "object", "additionalProperties": false, "description": "a record",
+				"properties": {"a": {"type": "string", "description": "a field"}}, "required": ["a"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := AvroToJSONSchema([]byte(tt.avro))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+
+			// Converting back gives an Avro schema again
+			back, _, err := JSONSchemaToAvro(got, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := MarshalAvro(back); err != nil {
+				t.Errorf("converting back: %v", err)
+			}
+		})
+	}
+}
+
+func TestAvroToJSONSchemaInvalid(t *testing.T) {
+	if _, err := AvroToJSONSchema([]byte(`{"type": "record"}`)); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+}
@@ -0,0 +1,257 @@
+package convert
+
+import "fmt"
+
+// walkTypeUnion converts a schema with a list of types to a union holding one
+// branch per type.
+func (c *converter) walkTypeUnion(schema *JSONSchema, recordName string) AvroSchema {
+	branches := make([]AvroSchema, 0, len(schema.Type))
+	for _, t := range schema.Type {
+		branch := *schema
+		branch.Type = SchemaType{t}
+		branches = append(branches, c.walkSchema(&branch, recordName))
+	}
+	return c.withDefault(c.union(branches, recordName), schema)
+}
+
+// walkComposition converts oneOf and anyOf to a union of their branches. The
+// keywords next to oneOf/anyOf apply to every branch, so they are merged
+// into each branch that is not a $ref.
+func (c *converter) walkComposition(schema *JSONSchema, recordName string) AvroSchema {
+	common := *schema
+	common.OneOf, common.AnyOf = nil, nil
+	common.Title, common.Description = "", ""
+	common.Default, common.hasDefault = nil, false
+
+	branches := []AvroSchema{}
+	for _, branch := range append(schema.OneOf, schema.AnyOf...) {
+		if branch.Ref == "" {
+			branch = mergeSchemas(branch, &common)
+		}
+		branches = append(branches, c.walkSchema(branch, recordName))
+	}
+	return c.withDefault(c.union(branches, recordName), schema)
+}
+
+// mergeAllOf merges the parts of allOf into a single schema. Keywords of the
+// schema itself take precedence over those of its parts, and earlier parts
+// take precedence over later ones.
+func (c *converter) mergeAllOf(schema *JSONSchema) *JSONSchema {
+	merged := *schema
+	merged.AllOf = nil
+	result := &merged
+	for _, part := range schema.AllOf {
+		if part.Ref != "" {
+			target, _, err := c.resolve(part.Ref)
+			if err != nil {
+				c.fail(err)
+				continue
+			}
+			part = target
+		}
+		if len(part.AllOf) > 0 {
+			part = c.mergeAllOf(part)
+		}
+		result = mergeSchemas(result, part)
+	}
+	return result
+}
+
+// mergeSchemas returns a copy of dst with the keywords it lacks taken from
+// src. Properties and required lists are combined.
+func mergeSchemas(dst, src *JSONSchema) *JSONSchema {
+	merged := *dst
+	if len(merged.Type) == 0 {
+		merged.Type = src.Type
+	}
+	if merged.Title == "" {
+		merged.Title = src.Title
+	}
+	if merged.Description == "" {
+		merged.Description = src.Description
+	}
+	if merged.Format == "" {
+		merged.Format = src.Format
+	}
+	if merged.Items == nil {
+		merged.Items = src.Items
+	}
+	if merged.PrefixItems == nil {
+		merged.PrefixItems = src.PrefixItems
+	}
+	if merged.AdditionalProperties == nil {
+		merged.AdditionalProperties = src.AdditionalProperties
+	}
+	if merged.PatternProperties == nil {
+		merged.PatternProperties = src.PatternProperties
+	}
+	if len(merged.Enum) == 0 {
+		merged.Enum = src.Enum
+	}
+	if merged.AvroDecimal == nil {
+		merged.AvroDecimal = src.AvroDecimal
+	}
+	if !merged.hasDefault {
+		merged.Default, merged.hasDefault = src.Default, src.hasDefault
+	}
+	if len(src.Properties) > 0 {
+		properties := map[string]*JSONSchema{}
+		for name, prop := range src.Properties {
+			properties[name] = prop
+		}
+		for name, prop := range dst.Properties {
+			properties[name] = prop
+		}
+		merged.Properties = properties
+	}
+	if len(src.Required) > 0 {
+		required := append([]string{}, dst.Required...)
+		for _, name := range src.Required {
+			if !contains(required, name) {
+				required = append(required, name)
+			}
+		}
+		merged.Required = required
+	}
+	return &merged
+}
+
+// union builds an Avro union from branches. Nested unions are flattened and
+// duplicate branches dropped, as Avro allows neither.
+func (c *converter) union(branches []AvroSchema, recordName string) AvroSchema {
+	types := []AvroSchema{}
+	seen := map[string]bool{}
+	for _, branch := range branches {
+		flat := []AvroSchema{branch}
+		if union, ok := branch.(*UnionType); ok {
+			flat = union.Types
+		}
+		for _, t := range flat {
+			key := unionKey(t)
+			if seen[key] {
+				if key == "array" || key == "map" {
+					c.warn("union %s has more than one %s branch, only the first one is kept", recordName, key)
+				}
+				continue
+			}
+			seen[key] = true
+			types = append(types, t)
+		}
+	}
+	if len(types) == 1 {
+		return types[0]
+	}
+	return &UnionType{Types: types}
+}
+
+// unionKey identifies the branches of a union that Avro considers the same.
+// Named types are told apart by name, other types by their type alone.
+func unionKey(t AvroSchema) string {
+	switch t := t.(type) {
+	case PrimitiveType:
+		return string(t)
+	case *LogicalType:
+		return string(t.Type)
+	case string:
+		return t
+	case *RecordType:
+		return t.Name
+	case *EnumType:
+		return t.Name
+	case *FixedType:
+		return t.Name
+	case *ArrayType:
+		return "array"
+	case *MapType:
+		return "map"
+	}
+	return fmt.Sprint(t)
+}
+
+// optional returns the union of t and null.
+func (c *converter) optional(t AvroSchema, recordName string) AvroSchema {
+	return c.union([]AvroSchema{t, NullType}, recordName)
+}
+
+// withDefault moves the branch matching the default of the schema to the
+// front of a union, as Avro requires the default to match the first branch.
+func (c *converter) withDefault(t AvroSchema, schema *JSONSchema) AvroSchema {
+	if schema.hasDefault {
+		t, _ = defaultFirst(t, schema.Default)
+	}
+	return t
+}
+
+// defaultFirst moves the first branch of a union that value is a valid
+// default of to the front. It reports whether value is a valid default of
+// the first branch of the result, or of t itself if it is not a union.
+func defaultFirst(t AvroSchema, value interface{}) (AvroSchema, bool) {
+	union, ok := t.(*UnionType)
+	if !ok {
+		return t, matchesDefault(t, value)
+	}
+	for i, branch := range union.Types {
+		if !matchesDefault(branch, value) {
+			continue
+		}
+		types := append([]AvroSchema{branch}, union.Types[:i]...)
+		types = append(types, union.Types[i+1:]...)
+		return &UnionType{Types: types}, true
+	}
+	return t, false
+}
+
+// matchesDefault reports whether value, decoded from JSON, is a valid
+// default of t. References to named types accept any value but null.
+func matchesDefault(t AvroSchema, value interface{}) bool {
+	switch t := t.(type) {
+	case PrimitiveType:
+		switch t {
+		case NullType:
+			return value == nil
+		case BooleanType:
+			_, ok := value.(bool)
+			return ok
+		case IntType, LongType:
+			n, ok := value.(float64)
+			return ok && n == float64(int64(n))
+		case FloatType, DoubleType:
+			_, ok := value.(float64)
+			return ok
+		case BytesType, StringType:
+			_, ok := value.(string)
+			return ok
+		}
+	case *LogicalType:
+		_, ok := logicalDefault(value, t)
+		return ok
+	case *EnumType:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, ok = t.values[s]
+		return ok
+	case *FixedType:
+		_, ok := value.(string)
+		return ok
+	case *RecordType, *MapType:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case *ArrayType:
+		_, ok := value.([]interface{})
+		return ok
+	case string:
+		return value != nil
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestTestCompatibility(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "orders", `{"type": "number"}`)
+	register(t, s, "orders", `{"type": "integer"}`)
+	if err := s.SetConfig("orders", &Config{Compatibility: compat.Backward}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		schema    string
+		version   int
+		want      []string
+		wantError error
+	}{
+		{name: "latest", schema: `{"type": "integer"}`, version: LatestVersion, want: []string{}},
+		{name: "latest narrowed", schema: `{"type": "integer", "minimum": 0}`, version: LatestVersion, want: []string{compat.ConstraintTightened}},
+		{name: "older version", schema: `{"type": "integer"}`, version: 1, want: []string{compat.TypeNarrowed}},
+		{name: "given version", schema: `{"type": "integer"}`, version: 2, want: []string{}},
+		{name: "missing version", schema: `{"type": "integer"}`, version: 3, wantError: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &Schema{Name: "orders"}
+			if err := schema.Parse([]byte(tt.schema)); err != nil {
+				t.Fatal(err)
+			}
+			level, violations, err := s.TestCompatibility(schema, tt.version)
+			if err != tt.wantError {
+				t.Fatalf("got error %v, want %v", err, tt.wantError)
+			}
+			if err != nil {
+				return
+			}
+			if level != compat.Backward {
+				t.Errorf("level = %s, want BACKWARD", level)
+			}
+			got := []string{}
+			for _, v := range violations {
+				got = append(got, v.Type)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Nothing is stored
+	if versions, _ := s.FindVersions("orders", true); len(versions) != 2 {
+		t.Errorf("got %d versions, want 2", len(versions))
+	}
+}
+
+func TestTestCompatibilityUnknownName(t *testing.T) {
+	s := newTestService(t)
+	schema := &Schema{Name: "orders"}
+	if err := schema.Parse([]byte(`{"type": "string"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.TestCompatibility(schema, LatestVersion); err != ErrNotFound {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+// Config holds the policies applied to new versions of a schema. The global
+// config has an empty Name and applies to every name without its own config.
+type Config struct {
+	Name          string       `bson:"name" json:"-"`
+	Compatibility compat.Level `bson:"compatibility" json:"compatibilityLevel"`
+}
+
+// DefaultCompatibility returns the level used when no global config is stored.
+func (s *SchemaService) DefaultCompatibility() compat.Level {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.compatibility
+}
+
+func (s *SchemaService) SetDefaultCompatibility(level compat.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compatibility = level
+}
+
+// GlobalConfig returns the stored global config, or one holding the default
+// compatibility level if none was stored.
+func (s *SchemaService) GlobalConfig() (*Config, error) {
+	config, err := s.store.FindConfig("")
+	if err == ErrConfigNotFound {
+		return &Config{Compatibility: s.DefaultCompatibility()}, nil
+	}
+	return config, err
+}
+
+func (s *SchemaService) SetGlobalConfig(config *Config) error {
+	config.Name = ""
+	return s.store.SaveConfig(config)
+}
+
+// FindConfig returns the config stored for name. It returns ErrConfigNotFound
+// if the name uses the global config.
+func (s *SchemaService) FindConfig(name string) (*Config, error) {
+	return s.store.FindConfig(name)
+}
+
+// EffectiveConfig returns the config stored for name, falling back to the
+// global config.
+func (s *SchemaService) EffectiveConfig(name string) (*Config, error) {
+	config, err := s.store.FindConfig(name)
+	if err == ErrConfigNotFound {
+		return s.GlobalConfig()
+	}
+	return config, err
+}
+
+func (s *SchemaService) SetConfig(name string, config *Config) error {
+	config.Name = name
+	return s.store.SaveConfig(config)
+}
+
+// DeleteConfig removes the config of name and returns it, after which the
+// name falls back to the global config.
+func (s *SchemaService) DeleteConfig(name string) (*Config, error) {
+	config, err := s.store.FindConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.DeleteConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Compatibility returns the level that applies to new versions of name.
+func (s *SchemaService) Compatibility(name string) (compat.Level, error) {
+	config, err := s.EffectiveConfig(name)
+	if err != nil {
+		return "", err
+	}
+	return config.Compatibility, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestCompatibilityConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		global compat.Level
+		own    compat.Level
+		want   compat.Level
+	}{
+		{name: "default", want: compat.Backward},
+		{name: "global", global: compat.Full, want: compat.Full},
+		{name: "per name", own: compat.None, want: compat.None},
+		{name: "per name over global", global: compat.Full, own: compat.Forward, want: compat.Forward},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSchemaService(NewMemoryStore())
+			if tt.global != "" {
+				if err := s.SetGlobalConfig(&Config{Compatibility: tt.global}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.own != "" {
+				if err := s.SetConfig("orders", &Config{Compatibility: tt.own}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if level, err := s.Compatibility("orders"); err != nil || level != tt.want {
+				t.Errorf("Compatibility(orders) = %s, %v, want %s", level, err, tt.want)
+			}
+			// Other names are not affected by the config of orders
+			want := tt.global
+			if want == "" {
+				want = compat.Backward
+			}
+			if level, err := s.Compatibility("invoices"); err != nil || level != want {
+				t.Errorf("Compatibility(invoices) = %s, %v, want %s", level, err, want)
+			}
+		})
+	}
+}
+
+func TestDeleteConfig(t *testing.T) {
+	s := NewSchemaService(NewMemoryStore())
+	if _, err := s.DeleteConfig("orders"); err != ErrConfigNotFound {
+		t.Errorf("got %v, want ErrConfigNotFound", err)
+	}
+	if err := s.SetGlobalConfig(&Config{Compatibility: compat.Full}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetConfig("orders", &Config{Compatibility: compat.None}); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.DeleteConfig("orders")
+	if err != nil || deleted.Compatibility != compat.None {
+		t.Fatalf("DeleteConfig(orders) = %v, %v, want NONE", deleted, err)
+	}
+	if _, err := s.FindConfig("orders"); err != ErrConfigNotFound {
+		t.Errorf("FindConfig(orders): got %v, want ErrConfigNotFound", err)
+	}
+	if config, err := s.EffectiveConfig("orders"); err != nil || config.Compatibility != compat.Full {
+		t.Errorf("EffectiveConfig(orders) = %v, %v, want FULL", config, err)
+	}
+}
+
+// TestCompatibilityConfigOnRegister checks that registrations use the level
+// of their name.
+func TestCompatibilityConfigOnRegister(t *testing.T) {
+	s := NewSchemaService(NewMemoryStore())
+	register(t, s, "orders", `{"type": "string"}`)
+	if _, err := tryRegister(s, &Schema{Name: "orders"}, `{"type": "integer"}`); err == nil {
+		t.Fatal("BACKWARD accepted a type change")
+	}
+	if err := s.SetConfig("orders", &Config{Compatibility: compat.None}); err != nil {
+		t.Fatal(err)
+	}
+	register(t, s, "orders", `{"type": "integer"}`)
+}
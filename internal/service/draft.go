@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Draft is the version of JSON Schema a JSON schema is written in.
+type Draft string
+
+const (
+	Draft04     Draft = "draft-04"
+	Draft06     Draft = "draft-06"
+	Draft07     Draft = "draft-07"
+	Draft201909 Draft = "2019-09"
+	Draft202012 Draft = "2020-12"
+)
+
+// DefaultDraft is the draft of schemas without a $schema keyword.
+const DefaultDraft = Draft07
+
+// draftURLs maps the meta-schema URLs, without scheme and trailing "#", to
+// their draft.
+var draftURLs = map[string]Draft{
+	"json-schema.org/draft-04/schema":      Draft04,
+	"json-schema.org/draft-06/schema":      Draft06,
+	"json-schema.org/draft-07/schema":      Draft07,
+	"json-schema.org/draft/2019-09/schema": Draft201909,
+	"json-schema.org/draft/2020-12/schema": Draft202012,
+}
+
+// URL returns the meta-schema URL of the draft.
+func (d Draft) URL() string {
+	for url, draft := range draftURLs {
+		if draft == d {
+			return "https://" + url
+		}
+	}
+	return ""
+}
+
+// detectDraft returns the draft declared by the $schema keyword of doc, or
+// DefaultDraft if there is none.
+func detectDraft(doc map[string]interface{}) (Draft, error) {
+	value, ok := doc["$schema"]
+	if !ok {
+		return DefaultDraft, nil
+	}
+	url, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: $schema must be a string", ErrInvalidSchema)
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://")
+	key = strings.TrimSuffix(strings.TrimSuffix(key, "/"), "#")
+	draft, ok := draftURLs[key]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported $schema %s, supported are draft-04, draft-06, draft-07, 2019-09 and 2020-12", ErrInvalidSchema, url)
+	}
+	return draft, nil
+}
+
+// checkDraft validates doc against the meta-schema of the draft it declares
+// and returns the draft.
+func checkDraft(doc map[string]interface{}) (Draft, error) {
+	draft, err := detectDraft(doc)
+	if err != nil {
+		return "", err
+	}
+	// The meta-schemas of all drafts are built into jsonschema, so this
+	// does not load anything
+	meta, err := jsonschema.Compile(draft.URL())
+	if err != nil {
+		return "", err
+	}
+	if err := meta.Validate(doc); err != nil {
+		if verr, ok := err.(*jsonschema.ValidationError); ok {
+			messages := []string{}
+			for _, leaf := range validationLeaves(verr) {
+				messages = append(messages, fmt.Sprintf("%s: %s", leaf.InstanceLocation, leaf.Message))
+			}
+			return "", fmt.Errorf("%w: not a valid %s schema: %s", ErrInvalidSchema, draft, strings.Join(messages, "; "))
+		}
+		return "", fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return draft, nil
+}
+
+// validationLeaves returns the errors of err that have no causes, which are
+// the ones pointing at the offending values.
+func validationLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	leaves := []*jsonschema.ValidationError{}
+	for _, cause := range err.Causes {
+		leaves = append(leaves, validationLeaves(cause)...)
+	}
+	return leaves
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDraft(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		draft  Draft
+		err    bool
+	}{
+		{name: "default", schema: `{"type": "string"}`, draft: Draft07},
+		{name: "draft-04", schema: `{"$schema": "http://json-schema.org/draft-04/schema#", "type": "number", "minimum": 0, "exclusiveMinimum": true}`, draft: Draft04},
+		{name: "draft-06", schema: `{"$schema": "http://json-schema.org/draft-06/schema#", "type": "number", "exclusiveMinimum": 0}`, draft: Draft06},
+		{name: "draft-07 over https", schema: `{"$schema": "https://json-schema.org/draft-07/schema", "if": {"type": "string"}, "then": {"minLength": 1}}`, draft: Draft07},
+		{name: "2019-09", schema: `{"$schema": "https://json-schema.org/draft/2019-09/schema", "$recursiveAnchor": true, "type": "array", "items": {"$recursiveRef": "#"}}`, draft: Draft201909},
+		{name: "2020-12", schema: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "$dynamicAnchor": "node", "type": "array", "prefixItems": [{"type": "string"}], "items": {"$dynamicRef": "#node"}}`, draft: Draft202012},
+		{name: "draft-04 numeric exclusiveMinimum", schema: `{"$schema": "http://json-schema.org/draft-04/schema#", "exclusiveMinimum": 0}`, err: true},
+		{name: "draft-06 boolean exclusiveMinimum", schema: `{"$schema": "http://json-schema.org/draft-06/schema#", "exclusiveMinimum": true}`, err: true},
+		{name: "2020-12 invalid prefixItems", schema: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "prefixItems": {"type": "string"}}`, err: true},
+		{name: "unsupported draft", schema: `{"$schema": "http://json-schema.org/draft-03/schema#"}`, err: true},
+		{name: "non-string $schema", schema: `{"$schema": 7}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &Schema{Name: "orders"}
+			err := schema.Parse([]byte(tt.schema))
+			if tt.err {
+				if !errors.Is(err, ErrInvalidSchema) {
+					t.Fatalf("got error %v, want ErrInvalidSchema", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Draft != tt.draft {
+				t.Errorf("got draft %q, want %q", schema.Draft, tt.draft)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"text/scanner"
+)
+
+// Canonical returns the canonical form of the schema, which is the same for
+// schemas that differ only in formatting:
+//   - JSON: the document with sorted keys and numbers in their shortest form
+//   - AVRO: the schema JSON, normalized the same way. The Parsing Canonical
+//     Form of the Avro specification is not used, as it drops defaults and
+//     aliases, which decide what readers of the schema accept.
+//   - PROTOBUF: the tokens of the source, without comments, separated by
+//     single spaces
+func (s *Schema) Canonical() ([]byte, error) {
+	switch s.Type() {
+	case AVRO:
+		var doc interface{}
+		if err := json.Unmarshal([]byte(s.Source), &doc); err != nil {
+			return nil, err
+		}
+		return canonicalJSON(doc)
+	case PROTOBUF:
+		return canonicalProtobuf(s.Source), nil
+	}
+
+	doc, err := s.Document()
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(doc)
+}
+
+// canonicalJSON encodes a decoded JSON value: encoding/json sorts map keys
+// and writes float64 values in their shortest form.
+func canonicalJSON(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// setFingerprint sets Fingerprint to the hex SHA-256 of the canonical form.
+func (s *Schema) setFingerprint() error {
+	canonical, err := s.Canonical()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(canonical)
+	s.Fingerprint = hex.EncodeToString(sum[:])
+	return nil
+}
+
+func canonicalProtobuf(source string) []byte {
+	var sc scanner.Scanner
+	sc.Init(strings.NewReader(source))
+	sc.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanStrings |
+		scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	sc.Error = func(*scanner.Scanner, string) {}
+
+	tokens := []string{}
+	for tok := sc.Scan(); tok != scanner.EOF; tok = sc.Scan() {
+		tokens = append(tokens, sc.TokenText())
+	}
+	return []byte(strings.Join(tokens, " "))
+}
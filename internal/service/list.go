@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListSort is the order of a listing. Versions with the same name, or created
+// or updated at the same time, are ordered by version or id so every position
+// in a listing is unique.
+type ListSort string
+
+const (
+	SortByName    ListSort = "name"
+	SortByCreated ListSort = "created"
+	SortByUpdated ListSort = "updated"
+)
+
+const (
+	// DefaultListLimit is the page size of listings that do not ask for one.
+	DefaultListLimit = 100
+	// MaxListLimit bounds the page size of listings.
+	MaxListLimit = 1000
+)
+
+// ErrInvalidCursor is returned for a cursor that was not returned by a listing
+// with the same sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery selects a page of schema versions. Zero values do not filter;
+// time ranges include their start and exclude their end. Versions must have
+// all of Tags and Labels.
+type ListQuery struct {
+	NamePrefix     string
+	SchemaType     SchemaType
+	Owner          string
+	Tags           []string
+	Labels         map[string]string
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	UpdatedFrom    time.Time
+	UpdatedTo      time.Time
+	IncludeDeleted bool
+
+	Sort       ListSort
+	Descending bool
+	Limit      int
+	// Cursor is the Next of the previous page, empty for the first page
+	Cursor string
+}
+
+// ListPage is a page of a listing. Next is empty on the last page.
+type ListPage struct {
+	Schemas []*Schema `json:"schemas"`
+	Next    string    `json:"next,omitempty"`
+}
+
+// ParseListSort parses a sort order, the empty string being SortByName.
+func ParseListSort(s string) (ListSort, error) {
+	switch sort := ListSort(strings.ToLower(s)); sort {
+	case "":
+		return SortByName, nil
+	case SortByName, SortByCreated, SortByUpdated:
+		return sort, nil
+	}
+	return "", fmt.Errorf("invalid sort: %s", s)
+}
+
+// List returns a page of the versions matching query.
+func (s *SchemaService) List(query ListQuery) (*ListPage, error) {
+	if query.Sort == "" {
+		query.Sort = SortByName
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultListLimit
+	} else if query.Limit > MaxListLimit {
+		query.Limit = MaxListLimit
+	}
+	var after *Schema
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeCursor(query.Cursor, query.Sort, query.Descending)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Ask for one more to know whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	schemas, err := s.store.FindPage(query, after)
+	if err != nil {
+		return nil, err
+	}
+	page := &ListPage{Schemas: schemas}
+	if len(schemas) > limit {
+		page.Schemas = schemas[:limit]
+		page.Next = encodeCursor(page.Schemas[limit-1], query.Sort, query.Descending)
+	}
+	return page, nil
+}
+
+// cursor is the position of a listing after the version it was made from.
+type cursor struct {
+	Sort       ListSort  `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	ID         string    `json:"i"`
+	Name       string    `json:"n,omitempty"`
+	Version    int       `json:"v,omitempty"`
+	CreatedAt  time.Time `json:"c,omitempty"`
+	UpdatedAt  time.Time `json:"u,omitempty"`
+}
+
+func encodeCursor(schema *Schema, sort ListSort, descending bool) string {
+	c := cursor{Sort: sort, Descending: descending, ID: schema.ID.Hex()}
+	switch sort {
+	case SortByCreated:
+		c.CreatedAt = schema.CreatedAt
+	case SortByUpdated:
+		c.UpdatedAt = schema.UpdatedAt
+	default:
+		c.Name, c.Version = schema.Name, schema.Version
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns a schema holding the sort keys of the position of
+// cursor.
+func decodeCursor(s string, sort ListSort, descending bool) (*Schema, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.Descending != descending {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Schema{ID: id, Name: c.Name, Version: c.Version, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}, nil
+}
+
+// compareListed orders a and b by sort, ascending.
+func compareListed(a, b *Schema, sort ListSort) int {
+	switch sort {
+	case SortByCreated, SortByUpdated:
+		at, bt := a.CreatedAt, b.CreatedAt
+		if sort == SortByUpdated {
+			at, bt = a.UpdatedAt, b.UpdatedAt
+		}
+		if !at.Equal(bt) {
+			if at.Before(bt) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.ID.Hex(), b.ID.Hex())
+	}
+	if a.Name != b.Name {
+		return strings.Compare(a.Name, b.Name)
+	}
+	return a.Version - b.Version
+}
+
+// matches reports whether schema passes the filters of query.
+func (query ListQuery) matches(schema *Schema) bool {
+	switch {
+	case schema.Deleted && !query.IncludeDeleted,
+		!strings.HasPrefix(schema.Name, query.NamePrefix),
+		query.SchemaType != "" && schema.Type() != query.SchemaType,
+		!inRange(schema.CreatedAt, query.CreatedFrom, query.CreatedTo),
+		!inRange(schema.UpdatedAt, query.UpdatedFrom, query.UpdatedTo),
+		query.Owner != "" && schema.Metadata.Owner != query.Owner:
+		return false
+	}
+	for _, tag := range query.Tags {
+		if !containsTag(schema.Metadata.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range query.Labels {
+		if label, ok := schema.Metadata.Labels[key]; !ok || label != value {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// listAll follows the cursors of query and returns every listed version as
+// "name/version".
+func listAll(t *testing.T, s *SchemaService, query ListQuery) []string {
+	t.Helper()
+	listed := []string{}
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("listing does not end")
+		}
+		page, err := s.List(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Schemas) > query.Limit {
+			t.Fatalf("got %d versions, limit %d", len(page.Schemas), query.Limit)
+		}
+		for _, schema := range page.Schemas {
+			listed = append(listed, fmt.Sprintf("%s/%d", schema.Name, schema.Version))
+		}
+		if page.Next == "" {
+			return listed
+		}
+		query.Cursor = page.Next
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	s := newTestService(t)
+	for _, name := range []string{"c", "a", "b"} {
+		register(t, s, name, `{"type": "string"}`)
+		register(t, s, name, `{"type": "integer"}`)
+	}
+	register(t, s, "ab", `{"type": "string"}`)
+	if _, err := s.PatchMetadata("b", &MetadataPatch{Tags: &[]string{"payments"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVersion("c", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	a1, err := s.FindByNameAndVersion("a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  []string
+	}{
+		{
+			name:  "by name",
+			query: ListQuery{Limit: 2},
+			want:  []string{"a/1", "a/2", "ab/1", "b/1", "b/2", "c/1"},
+		},
+		{
+			name:  "by name descending",
+			query: ListQuery{Limit: 4, Descending: true},
+			want:  []string{"c/1", "b/2", "b/1", "ab/1", "a/2", "a/1"},
+		},
+		{
+			name:  "deleted included",
+			query: ListQuery{Limit: 5, IncludeDeleted: true},
+			want:  []string{"a/1", "a/2", "ab/1", "b/1", "b/2", "c/1", "c/2"},
+		},
+		{
+			name:  "prefix",
+			query: ListQuery{Limit: 1, NamePrefix: "a"},
+			want:  []string{"a/1", "a/2", "ab/1"},
+		},
+		{
+			name:  "tag",
+			query: ListQuery{Limit: 10, Tags: []string{"payments"}},
+			want:  []string{"b/1", "b/2"},
+		},
+		{
+			name:  "by creation",
+			query: ListQuery{Limit: 4, Sort: SortByCreated},
+			want:  []string{"c/1", "a/1", "a/2", "b/1", "b/2", "ab/1"},
+		},
+		{
+			name:  "created from",
+			query: ListQuery{Limit: 2, Sort: SortByCreated, Descending: true, CreatedFrom: a1.CreatedAt},
+			want:  []string{"ab/1", "b/2", "b/1", "a/2", "a/1"},
+		},
+		{
+			name:  "no match",
+			query: ListQuery{Limit: 10, Owner: "nobody"},
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listAll(t, s, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "a", `{"type": "string"}`)
+	register(t, s, "b", `{"type": "string"}`)
+
+	page, err := s.List(ListQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Next == "" {
+		t.Fatal("expected a next page")
+	}
+	tests := []struct {
+		name  string
+		query ListQuery
+	}{
+		{"garbage", ListQuery{Cursor: "not a cursor"}},
+		{"other sort", ListQuery{Cursor: page.Next, Sort: SortByUpdated}},
+		{"other order", ListQuery{Cursor: page.Next, Descending: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.List(tt.query); err != ErrInvalidCursor {
+				t.Errorf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
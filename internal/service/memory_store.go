@@ -0,0 +1,350 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore is a SchemaStore that keeps all documents in memory. It is meant
+// for tests and local runs without MongoDB; nothing survives a restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	schemas []*Schema
+	configs map[string]Config
+	// lastSchemaID is the last schema id handed out and schemaIDs holds
+	// the id of every content key
+	lastSchemaID int
+	schemaIDs    map[string]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{configs: map[string]Config{}, schemaIDs: map[string]int{}}
+}
+
+func (s *MemoryStore) Create(schema *Schema) (*Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.schemas {
+		if stored.Name == schema.Name && stored.Version == schema.Version {
+			return nil, ErrVersionExists
+		}
+	}
+	schema.ID = primitive.NewObjectID()
+	stored := *schema
+	s.schemas = append(s.schemas, &stored)
+	return schema, nil
+}
+
+func (s *MemoryStore) FindAll(includeDeleted bool) ([]*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schemas := make([]*Schema, 0, len(s.schemas))
+	for _, schema := range s.schemas {
+		if schema.Deleted && !includeDeleted {
+			continue
+		}
+		copied := *schema
+		schemas = append(schemas, &copied)
+	}
+	return schemas, nil
+}
+
+func (s *MemoryStore) FindByID(id string) (*Schema, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.findOne(func(schema *Schema) bool {
+		return schema.ID == objID && !schema.Deleted
+	})
+}
+
+func (s *MemoryStore) FindBySchemaID(id int) (*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := []*Schema{}
+	for _, schema := range s.schemas {
+		if schema.SchemaID == id {
+			versions = append(versions, schema)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Name != versions[j].Name {
+			return versions[i].Name < versions[j].Name
+		}
+		return versions[i].Version < versions[j].Version
+	})
+	preferred := preferredVersion(versions)
+	if preferred == nil {
+		return nil, ErrNotFound
+	}
+	copied := *preferred
+	return &copied, nil
+}
+
+func (s *MemoryStore) FindByFingerprint(fingerprint string) ([]*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schemas := []*Schema{}
+	for _, schema := range s.schemas {
+		if schema.Fingerprint == fingerprint {
+			copied := *schema
+			schemas = append(schemas, &copied)
+		}
+	}
+	return schemas, nil
+}
+
+func (s *MemoryStore) FindByName(name string) (*Schema, error) {
+	return s.findOne(func(schema *Schema) bool {
+		return schema.Name == name && !schema.Deleted && schema.Lifecycle() != StateDraft
+	})
+}
+
+func (s *MemoryStore) FindByNameAndVersion(name string, version int) (*Schema, error) {
+	return s.findOne(func(schema *Schema) bool {
+		return schema.Name == name && schema.Version == version && !schema.Deleted
+	})
+}
+
+func (s *MemoryStore) FindVersions(name string, includeDeleted bool) ([]*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schemas := []*Schema{}
+	for _, schema := range s.schemas {
+		if schema.Name == name && (includeDeleted || !schema.Deleted) {
+			copied := *schema
+			schemas = append(schemas, &copied)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Version < schemas[j].Version
+	})
+	return schemas, nil
+}
+
+func (s *MemoryStore) FindPage(query ListQuery, after *Schema) ([]*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	compare := func(a, b *Schema) int {
+		if query.Descending {
+			return compareListed(b, a, query.Sort)
+		}
+		return compareListed(a, b, query.Sort)
+	}
+	schemas := []*Schema{}
+	for _, schema := range s.schemas {
+		if query.matches(schema) && (after == nil || compare(after, schema) < 0) {
+			copied := *schema
+			schemas = append(schemas, &copied)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return compare(schemas[i], schemas[j]) < 0
+	})
+	if len(schemas) > query.Limit {
+		schemas = schemas[:query.Limit]
+	}
+	return schemas, nil
+}
+
+func (s *MemoryStore) FindNames(includeDeleted bool) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, schema := range s.schemas {
+		if (includeDeleted || !schema.Deleted) && !seen[schema.Name] {
+			seen[schema.Name] = true
+			names = append(names, schema.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *MemoryStore) FindVersionInfos(name string, includeDeleted bool) ([]VersionInfo, error) {
+	schemas, err := s.FindVersions(name, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]VersionInfo, 0, len(schemas))
+	for _, schema := range schemas {
+		versions = append(versions, VersionInfo{
+			Version:   schema.Version,
+			CreatedAt: schema.CreatedAt,
+			UpdatedAt: schema.UpdatedAt,
+			State:     schema.State,
+			Deleted:   schema.Deleted,
+		})
+	}
+	return versions, nil
+}
+
+func (s *MemoryStore) FindReferencedBy(name string, version int, includeDeleted bool) ([]*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schemas := []*Schema{}
+	for _, schema := range s.schemas {
+		if schema.Deleted && !includeDeleted {
+			continue
+		}
+		for _, ref := range schema.References {
+			if ref.Name == name && ref.Version == version {
+				copied := *schema
+				schemas = append(schemas, &copied)
+				break
+			}
+		}
+	}
+	return schemas, nil
+}
+
+func (s *MemoryStore) Update(schema *Schema) (*Schema, error) {
+	return s.Create(schema)
+}
+
+func (s *MemoryStore) SetMetadata(id string, metadata *Metadata) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, schema := range s.schemas {
+		if schema.ID == objID {
+			schema.Metadata = *metadata
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) SetState(id string, state State, deprecation *Deprecation) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, schema := range s.schemas {
+		if schema.ID == objID {
+			schema.State, schema.Deprecation = state, deprecation
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) SoftDelete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, schema := range s.schemas {
+		if schema.ID == objID && !schema.Deleted {
+			schema.Deleted = true
+			now := time.Now()
+			schema.DeletedAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, schema := range s.schemas {
+		if schema.ID == objID {
+			s.schemas = append(s.schemas[:i], s.schemas[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) SchemaID(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.schemaIDs[key]; ok {
+		return id, nil
+	}
+	s.lastSchemaID++
+	s.schemaIDs[key] = s.lastSchemaID
+	return s.lastSchemaID, nil
+}
+
+func (s *MemoryStore) FindConfig(name string) (*Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	config, ok := s.configs[name]
+	if !ok {
+		return nil, ErrConfigNotFound
+	}
+	return &config, nil
+}
+
+func (s *MemoryStore) SaveConfig(config *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.configs[config.Name] = *config
+	return nil
+}
+
+func (s *MemoryStore) DeleteConfig(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.configs[name]; !ok {
+		return ErrConfigNotFound
+	}
+	delete(s.configs, name)
+	return nil
+}
+
+// findOne returns a copy of the matching document with the highest version.
+func (s *MemoryStore) findOne(match func(*Schema) bool) (*Schema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *Schema
+	for _, schema := range s.schemas {
+		if match(schema) && (found == nil || schema.Version > found.Version) {
+			found = schema
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
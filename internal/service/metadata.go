@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidMetadata is returned for a label key MongoDB cannot store or
+// query.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata describes who owns a schema and what it is for. It is stored on
+// every version; new versions start with the metadata of the latest version.
+// It is serialized with the field names MetadataPatch accepts.
+type Metadata struct {
+	Owner       string            `bson:"owner,omitempty" json:"owner,omitempty"`
+	Contact     string            `bson:"contact,omitempty" json:"contact,omitempty"`
+	Description string            `bson:"description,omitempty" json:"description,omitempty"`
+	Tags        []string          `bson:"tags,omitempty" json:"tags,omitempty"`
+	Labels      map[string]string `bson:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// MetadataPatch changes the fields of Metadata that are set, like a JSON merge
+// patch: a nil field is kept, Tags replaces the tags, and each label is set,
+// or removed when its value is nil.
+type MetadataPatch struct {
+	Owner       *string            `json:"owner"`
+	Contact     *string            `json:"contact"`
+	Description *string            `json:"description"`
+	Tags        *[]string          `json:"tags"`
+	Labels      map[string]*string `json:"labels"`
+}
+
+// Empty reports whether the patch changes nothing.
+func (p *MetadataPatch) Empty() bool {
+	return p.Owner == nil && p.Contact == nil && p.Description == nil && p.Tags == nil && len(p.Labels) == 0
+}
+
+// Validate checks the label keys of the patch, which must be non-empty and
+// neither contain "." nor start with "$".
+func (p *MetadataPatch) Validate() error {
+	for key := range p.Labels {
+		if key == "" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return fmt.Errorf("%w: label key %q", ErrInvalidMetadata, key)
+		}
+	}
+	return nil
+}
+
+// Apply changes m as described by the patch. The labels of m are copied
+// before they are changed.
+func (p *MetadataPatch) Apply(m *Metadata) {
+	if p.Owner != nil {
+		m.Owner = *p.Owner
+	}
+	if p.Contact != nil {
+		m.Contact = *p.Contact
+	}
+	if p.Description != nil {
+		m.Description = *p.Description
+	}
+	if p.Tags != nil {
+		m.Tags = nil
+		seen := map[string]bool{}
+		for _, tag := range *p.Tags {
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+	}
+	if len(p.Labels) == 0 {
+		return
+	}
+	labels := map[string]string{}
+	for key, value := range m.Labels {
+		labels[key] = value
+	}
+	for key, value := range p.Labels {
+		if value == nil {
+			delete(labels, key)
+		} else {
+			labels[key] = *value
+		}
+	}
+	m.Labels = labels
+	if len(labels) == 0 {
+		m.Labels = nil
+	}
+}
+
+// PatchMetadata applies patch to the metadata of every version of name,
+// soft-deleted ones included, and returns the newest live version. It
+// returns ErrNotFound, and changes nothing, if name has no live version.
+func (s *SchemaService) PatchMetadata(name string, patch *MetadataPatch) (*Schema, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	versions, err := s.store.FindVersions(name, true)
+	if err != nil {
+		return nil, err
+	}
+	live := false
+	for _, version := range versions {
+		live = live || !version.Deleted
+	}
+	if !live {
+		return nil, ErrNotFound
+	}
+	for _, version := range versions {
+		patch.Apply(&version.Metadata)
+		if err := s.store.SetMetadata(version.ID.Hex(), &version.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return s.FindNewest(name)
+}
+
+// PatchVersionMetadata applies patch to the metadata of a single version and
+// returns it.
+func (s *SchemaService) PatchVersionMetadata(name string, version int, patch *MetadataPatch) (*Schema, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	schema, err := s.store.FindByNameAndVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	patch.Apply(&schema.Metadata)
+	if err := s.store.SetMetadata(schema.ID.Hex(), &schema.Metadata); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMetadataPatchApply(t *testing.T) {
+	owner, empty := "team-y", ""
+	prod := "prod"
+	tests := []struct {
+		name  string
+		patch MetadataPatch
+		want  Metadata
+	}{
+		{
+			name:  "empty",
+			patch: MetadataPatch{},
+			want:  Metadata{Owner: "team-x", Tags: []string{"a"}, Labels: map[string]string{"tier": "1"}},
+		},
+		{
+			name:  "owner",
+			patch: MetadataPatch{Owner: &owner},
+			want:  Metadata{Owner: "team-y", Tags: []string{"a"}, Labels: map[string]string{"tier": "1"}},
+		},
+		{
+			name:  "owner cleared",
+			patch: MetadataPatch{Owner: &empty},
+			want:  Metadata{Tags: []string{"a"}, Labels: map[string]string{"tier": "1"}},
+		},
+		{
+			name:  "tags replaced",
+			patch: MetadataPatch{Tags: &[]string{"b", "", "c", "b"}},
+			want:  Metadata{Owner: "team-x", Tags: []string{"b", "c"}, Labels: map[string]string{"tier": "1"}},
+		},
+		{
+			name:  "labels",
+			patch: MetadataPatch{Labels: map[string]*string{"tier": nil, "env": &prod}},
+			want:  Metadata{Owner: "team-x", Tags: []string{"a"}, Labels: map[string]string{"env": "prod"}},
+		},
+		{
+			name:  "last label removed",
+			patch: MetadataPatch{Labels: map[string]*string{"tier": nil}},
+			want:  Metadata{Owner: "team-x", Tags: []string{"a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{"tier": "1"}
+			m := Metadata{Owner: "team-x", Tags: []string{"a"}, Labels: labels}
+			tt.patch.Apply(&m)
+			if !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("got %+v, want %+v", m, tt.want)
+			}
+			if labels["tier"] != "1" || len(labels) != 1 {
+				t.Errorf("the labels of the patched metadata were changed in place: %v", labels)
+			}
+		})
+	}
+}
+
+func TestMetadataJSON(t *testing.T) {
+	// Metadata is served with the field names of the PATCH body
+	var patch MetadataPatch
+	body := `{"owner": "team-x", "contact": "x@example.com", "description": "orders", "tags": ["a"], "labels": {"tier": "1"}}`
+	if err := json.Unmarshal([]byte(body), &patch); err != nil {
+		t.Fatal(err)
+	}
+	var m Metadata
+	patch.Apply(&m)
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"owner":"team-x","contact":"x@example.com","description":"orders","tags":["a"],"labels":{"tier":"1"}}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestPatchMetadata(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "a", `{"type": "string"}`)
+	register(t, s, "a", `{"type": "integer"}`)
+	register(t, s, "a", `{"type": "boolean"}`)
+	if err := s.DeleteVersion("a", 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := "team-x"
+	newest, err := s.PatchMetadata("a", &MetadataPatch{Owner: &owner, Tags: &[]string{"payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newest.Version != 2 || newest.Metadata.Owner != owner {
+		t.Errorf("got version %d with %+v, want version 2 owned by %s", newest.Version, newest.Metadata, owner)
+	}
+	// Soft-deleted versions are patched too
+	versions, err := s.store.FindVersions("a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions {
+		if version.Metadata.Owner != owner || !reflect.DeepEqual(version.Metadata.Tags, []string{"payments"}) {
+			t.Errorf("version %d has %+v", version.Version, version.Metadata)
+		}
+	}
+
+	// A single version
+	contact := "x@example.com"
+	v1, err := s.PatchVersionMetadata("a", 1, &MetadataPatch{Contact: &contact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Metadata.Contact != contact || v1.Metadata.Owner != owner {
+		t.Errorf("got %+v", v1.Metadata)
+	}
+	v2, err := s.FindByNameAndVersion("a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Metadata.Contact != "" {
+		t.Errorf("version 2 got contact %q", v2.Metadata.Contact)
+	}
+	// New versions start with the metadata of the latest one
+	if v4 := register(t, s, "a", `{"type": "number"}`); v4.Metadata.Owner != owner {
+		t.Errorf("new version got %+v", v4.Metadata)
+	}
+
+	if _, err := s.PatchMetadata("a", &MetadataPatch{Labels: map[string]*string{"a.b": &owner}}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("label with a dot: got %v, want ErrInvalidMetadata", err)
+	}
+	if _, err := s.PatchMetadata("missing", &MetadataPatch{Owner: &owner}); err != ErrNotFound {
+		t.Errorf("missing name: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.PatchVersionMetadata("a", 3, &MetadataPatch{Owner: &owner}); err != ErrNotFound {
+		t.Errorf("deleted version: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestPatchMetadataAllDeleted(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "a", `{"type": "string"}`)
+	if _, err := s.DeleteSchema("a", false); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := "team-x"
+	if _, err := s.PatchMetadata("a", &MetadataPatch{Owner: &owner}); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	versions, err := s.store.FindVersions("a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions {
+		if version.Metadata.Owner != "" {
+			t.Errorf("deleted version %d was patched: %+v", version.Version, version.Metadata)
+		}
+	}
+}
+
+func TestMetadataSearch(t *testing.T) {
+	s := newTestService(t)
+	for _, name := range []string{"orders", "payments", "users"} {
+		register(t, s, name, `{"type": "string"}`)
+	}
+	x, y, tier1, tier2 := "team-x", "team-y", "1", "2"
+	patches := map[string]*MetadataPatch{
+		"orders":   {Owner: &x, Tags: &[]string{"core", "sales"}, Labels: map[string]*string{"tier": &tier1}},
+		"payments": {Owner: &x, Tags: &[]string{"core"}, Labels: map[string]*string{"tier": &tier2}},
+		"users":    {Owner: &y, Tags: &[]string{"sales"}, Labels: map[string]*string{"tier": &tier1}},
+	}
+	for name, patch := range patches {
+		if _, err := s.PatchMetadata(name, patch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The patched metadata is inherited and searched on the new version
+	register(t, s, "users", `{"type": "integer"}`)
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  []string
+	}{
+		{name: "owner", query: ListQuery{Owner: "team-x"}, want: []string{"orders/1", "payments/1"}},
+		{name: "tag", query: ListQuery{Tags: []string{"sales"}}, want: []string{"orders/1", "users/1", "users/2"}},
+		{name: "all tags", query: ListQuery{Tags: []string{"core", "sales"}}, want: []string{"orders/1"}},
+		{name: "label", query: ListQuery{Labels: map[string]string{"tier": "1"}}, want: []string{"orders/1", "users/1", "users/2"}},
+		{name: "owner and label", query: ListQuery{Owner: "team-x", Labels: map[string]string{"tier": "2"}}, want: []string{"payments/1"}},
+		{name: "missing label", query: ListQuery{Labels: map[string]string{"env": "prod"}}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Limit = 10
+			if got := listAll(t, s, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s, want %s", strings.Join(got, " "), strings.Join(tt.want, " "))
+			}
+		})
+	}
+}
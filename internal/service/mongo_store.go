@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is a SchemaStore backed by a MongoDB collection. Configs are kept
+// in the "config" collection of the same database, sequences in the
+// "counters" collection and the schema ids of contents in the "schema_ids"
+// collection, keyed by content.
+type MongoStore struct {
+	collection *mongo.Collection
+	configs    *mongo.Collection
+	counters   *mongo.Collection
+	schemaIDs  *mongo.Collection
+}
+
+func NewMongoStore(client *mongo.Client, dbName, collectionName string) *MongoStore {
+	db := client.Database(dbName)
+	return &MongoStore{
+		collection: db.Collection(collectionName),
+		configs:    db.Collection("config"),
+		counters:   db.Collection("counters"),
+		schemaIDs:  db.Collection("schema_ids"),
+	}
+}
+
+// EnsureIndexes creates the indexes the queries of the store rely on.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "schema_id", Value: 1}}},
+		{Keys: bson.D{{Key: "fingerprint", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "metadata.tags", Value: 1}}},
+		{Keys: bson.D{{Key: "metadata.owner", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	return err
+}
+
+func (s *MongoStore) Create(schema *Schema) (*Schema, error) {
+	res, err := s.collection.InsertOne(context.Background(), schema)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrVersionExists
+		}
+		return nil, err
+	}
+	schema.ID = res.InsertedID.(primitive.ObjectID)
+	return schema, nil
+}
+
+func (s *MongoStore) FindAll(includeDeleted bool) ([]*Schema, error) {
+	return s.find(visible(bson.M{}, includeDeleted))
+}
+
+// visible adds a condition on the deleted flag to filter unless
+// includeDeleted is set.
+func visible(filter bson.M, includeDeleted bool) bson.M {
+	if !includeDeleted {
+		filter["deleted"] = bson.M{"$ne": true}
+	}
+	return filter
+}
+
+func (s *MongoStore) find(filter bson.M, opts ...*options.FindOptions) ([]*Schema, error) {
+	cursor, err := s.collection.Find(context.Background(), filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	schemas := []*Schema{}
+	for cursor.Next(context.Background()) {
+		schema := &Schema{}
+		err := cursor.Decode(schema)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+func (s *MongoStore) FindByID(id string) (*Schema, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.findOne(visible(bson.M{"_id": objID}, false))
+}
+
+func (s *MongoStore) FindBySchemaID(id int) (*Schema, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "version", Value: 1}})
+	versions, err := s.find(bson.M{"schema_id": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if preferred := preferredVersion(versions); preferred != nil {
+		return preferred, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MongoStore) FindByFingerprint(fingerprint string) ([]*Schema, error) {
+	return s.find(bson.M{"fingerprint": fingerprint})
+}
+
+func (s *MongoStore) FindByName(name string) (*Schema, error) {
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+	filter := bson.M{"name": name, "state": bson.M{"$ne": StateDraft}}
+	return s.findOne(visible(filter, false), opts)
+}
+
+func (s *MongoStore) FindByNameAndVersion(name string, version int) (*Schema, error) {
+	query := bson.M{
+		"name":    name,
+		"version": version,
+	}
+	return s.findOne(visible(query, false))
+}
+
+func (s *MongoStore) FindVersions(name string, includeDeleted bool) ([]*Schema, error) {
+	opts := options.Find().SetSort(bson.M{"version": 1})
+	return s.find(visible(bson.M{"name": name}, includeDeleted), opts)
+}
+
+func (s *MongoStore) FindPage(query ListQuery, after *Schema) ([]*Schema, error) {
+	filter := visible(bson.M{}, query.IncludeDeleted)
+	if query.NamePrefix != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(query.NamePrefix)}
+	}
+	if query.SchemaType == JSON {
+		// Versions stored before schema types have none
+		filter["schema_type"] = bson.M{"$in": bson.A{JSON, nil}}
+	} else if query.SchemaType != "" {
+		filter["schema_type"] = query.SchemaType
+	}
+	if query.Owner != "" {
+		filter["metadata.owner"] = query.Owner
+	}
+	if len(query.Tags) > 0 {
+		filter["metadata.tags"] = bson.M{"$all": query.Tags}
+	}
+	for key, value := range query.Labels {
+		filter["metadata.labels."+key] = value
+	}
+	if r := timeRange(query.CreatedFrom, query.CreatedTo); r != nil {
+		filter["created_at"] = r
+	}
+	if r := timeRange(query.UpdatedFrom, query.UpdatedTo); r != nil {
+		filter["updated_at"] = r
+	}
+
+	first, second := "name", "version"
+	switch query.Sort {
+	case SortByCreated:
+		first, second = "created_at", "_id"
+	case SortByUpdated:
+		first, second = "updated_at", "_id"
+	}
+	direction, op := 1, "$gt"
+	if query.Descending {
+		direction, op = -1, "$lt"
+	}
+	if after != nil {
+		firstValue, secondValue := interface{}(after.Name), interface{}(after.Version)
+		switch query.Sort {
+		case SortByCreated:
+			firstValue, secondValue = after.CreatedAt, after.ID
+		case SortByUpdated:
+			firstValue, secondValue = after.UpdatedAt, after.ID
+		}
+		filter["$or"] = bson.A{
+			bson.M{first: bson.M{op: firstValue}},
+			bson.M{first: firstValue, second: bson.M{op: secondValue}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: first, Value: direction}, {Key: second, Value: direction}}).
+		SetLimit(int64(query.Limit))
+	return s.find(filter, opts)
+}
+
+// timeRange returns a condition on a time between from, inclusive, and to,
+// exclusive, or nil if both are zero.
+func timeRange(from, to time.Time) bson.M {
+	if from.IsZero() && to.IsZero() {
+		return nil
+	}
+	r := bson.M{}
+	if !from.IsZero() {
+		r["$gte"] = from
+	}
+	if !to.IsZero() {
+		r["$lt"] = to
+	}
+	return r
+}
+
+func (s *MongoStore) FindNames(includeDeleted bool) ([]string, error) {
+	values, err := s.collection.Distinct(context.Background(), "name", visible(bson.M{}, includeDeleted))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		if name, ok := value.(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *MongoStore) FindVersionInfos(name string, includeDeleted bool) ([]VersionInfo, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: visible(bson.M{"name": name}, includeDeleted)}},
+		{{Key: "$sort", Value: bson.M{"version": 1}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "version": 1, "created_at": 1, "updated_at": 1, "state": 1, "deleted": 1}}},
+	}
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	versions := []VersionInfo{}
+	if err := cursor.All(context.Background(), &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (s *MongoStore) FindReferencedBy(name string, version int, includeDeleted bool) ([]*Schema, error) {
+	filter := bson.M{
+		"references": bson.M{
+			"$elemMatch": bson.M{"name": name, "version": version},
+		},
+	}
+	return s.find(visible(filter, includeDeleted))
+}
+
+func (s *MongoStore) Update(schema *Schema) (*Schema, error) {
+	// Versions are immutable, so an update is an insert of a new document
+	schema.ID = primitive.NilObjectID
+	return s.Create(schema)
+}
+
+func (s *MongoStore) SetMetadata(id string, metadata *Metadata) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"metadata": metadata}}
+	res, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) SetState(id string, state State, deprecation *Deprecation) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"state": state, "deprecation": deprecation}}
+	res, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) SoftDelete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"deleted": true, "deleted_at": time.Now()}}
+	res, err := s.collection.UpdateOne(context.Background(), visible(bson.M{"_id": objID}, false), update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) Delete(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SchemaID looks up the id of key in "schema_ids" and otherwise inserts the
+// next id of the "schema_id" counter. The key is the _id, so of concurrent
+// inserts for a key only the first succeeds and the others read its id; the
+// ids they allocated are skipped.
+func (s *MongoStore) SchemaID(key string) (int, error) {
+	id, err := s.findSchemaID(key)
+	if err != ErrNotFound {
+		return id, err
+	}
+	id, err = s.nextSchemaID()
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.schemaIDs.InsertOne(context.Background(), bson.M{"_id": key, "schema_id": id})
+	if mongo.IsDuplicateKeyError(err) {
+		return s.findSchemaID(key)
+	}
+	return id, err
+}
+
+func (s *MongoStore) findSchemaID(key string) (int, error) {
+	entry := struct {
+		SchemaID int `bson:"schema_id"`
+	}{}
+	err := s.schemaIDs.FindOne(context.Background(), bson.M{"_id": key}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return 0, ErrNotFound
+	}
+	return entry.SchemaID, err
+}
+
+// nextSchemaID increments the "schema_id" counter, creating it on first use.
+func (s *MongoStore) nextSchemaID() (int, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	counter := struct {
+		Seq int `bson:"seq"`
+	}{}
+	err := s.counters.FindOneAndUpdate(context.Background(),
+		bson.M{"_id": "schema_id"}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+func (s *MongoStore) FindConfig(name string) (*Config, error) {
+	config := &Config{}
+	err := s.configs.FindOne(context.Background(), bson.M{"name": name}).Decode(config)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrConfigNotFound
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+func (s *MongoStore) SaveConfig(config *Config) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.configs.ReplaceOne(context.Background(), bson.M{"name": config.Name}, config, opts)
+	return err
+}
+
+func (s *MongoStore) DeleteConfig(name string) error {
+	res, err := s.configs.DeleteOne(context.Background(), bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrConfigNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) findOne(filter bson.M, opts ...*options.FindOneOptions) (*Schema, error) {
+	schema := &Schema{}
+	err := s.collection.FindOne(context.Background(), filter, opts...).Decode(schema)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return schema, nil
+}
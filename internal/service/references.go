@@ -0,0 +1,266 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReferencePrefix starts a $ref that points to another registered schema, as
+// in "registry:Address/3".
+const ReferencePrefix = "registry:"
+
+var (
+	// ErrInvalidReference is returned when a schema references a schema
+	// version that is not registered.
+	ErrInvalidReference = errors.New("invalid schema reference")
+	// ErrReferenced is returned when deleting a version other schemas refer to.
+	ErrReferenced = errors.New("schema version is referenced by other schemas")
+)
+
+// Reference identifies a registered schema version referenced by another one.
+type Reference struct {
+	Name    string `bson:"name"`
+	Version int    `bson:"version"`
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s%s/%d", ReferencePrefix, r.Name, r.Version)
+}
+
+// parseReference parses a registry $ref. It returns false for any other $ref.
+func parseReference(ref string) (Reference, bool, error) {
+	if !strings.HasPrefix(ref, ReferencePrefix) {
+		return Reference{}, false, nil
+	}
+	target := strings.TrimPrefix(ref, ReferencePrefix)
+	i := strings.LastIndex(target, "/")
+	if i <= 0 {
+		return Reference{}, true, fmt.Errorf("%w: %s, expected %s<name>/<version>", ErrInvalidReference, ref, ReferencePrefix)
+	}
+	version, err := strconv.Atoi(target[i+1:])
+	if err != nil || version < 1 {
+		return Reference{}, true, fmt.Errorf("%w: %s, expected %s<name>/<version>", ErrInvalidReference, ref, ReferencePrefix)
+	}
+	return Reference{Name: target[:i], Version: version}, true, nil
+}
+
+// collectReferences walks a schema document and adds every registry
+// reference it contains to refs.
+func collectReferences(node interface{}, refs map[Reference]bool) error {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if s, ok := value.(string); ok && key == "$ref" {
+				ref, ok, err := parseReference(s)
+				if err != nil {
+					return err
+				}
+				if ok {
+					refs[ref] = true
+				}
+				continue
+			}
+			if err := collectReferences(value, refs); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, value := range node {
+			if err := collectReferences(value, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// references returns the registry references of schema, sorted, after
+// checking that every referenced version exists.
+func (s *SchemaService) references(schema *Schema) ([]Reference, error) {
+	if schema.Type() != JSON {
+		return nil, nil
+	}
+	doc, err := schema.Document()
+	if err != nil {
+		return nil, err
+	}
+	found := map[Reference]bool{}
+	if err := collectReferences(doc, found); err != nil {
+		return nil, err
+	}
+
+	refs := make([]Reference, 0, len(found))
+	for ref := range found {
+		target, err := s.store.FindByNameAndVersion(ref.Name, ref.Version)
+		if err == ErrNotFound {
+			return nil, fmt.Errorf("%w: %s is not registered", ErrInvalidReference, ref)
+		} else if err != nil {
+			return nil, err
+		}
+		if target.Type() != JSON {
+			return nil, fmt.Errorf("%w: %s is a %s schema", ErrInvalidReference, ref, target.Type())
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name != refs[j].Name {
+			return refs[i].Name < refs[j].Name
+		}
+		return refs[i].Version < refs[j].Version
+	})
+	return refs, nil
+}
+
+// FindReferencedBy returns the schema versions that reference name/version.
+func (s *SchemaService) FindReferencedBy(name string, version int) ([]*Schema, error) {
+	if _, err := s.store.FindByNameAndVersion(name, version); err != nil {
+		return nil, err
+	}
+	return s.store.FindReferencedBy(name, version, false)
+}
+
+// Resolve returns the schema body with every registry reference replaced by
+// a local reference. The referenced schemas are copied into "definitions"
+// under "<name>_v<version>", their own definitions under
+// "<name>_v<version>.<key>", so the result is a self-contained JSON Schema.
+func (s *SchemaService) Resolve(schema *Schema) (map[string]interface{}, error) {
+	doc, err := schema.Document()
+	if err != nil {
+		return nil, err
+	}
+	b := &bundler{
+		service:     s,
+		definitions: map[string]interface{}{},
+	}
+	resolved := b.rewrite(doc, "").(map[string]interface{})
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.definitions) > 0 {
+		definitions := map[string]interface{}{}
+		if existing, ok := resolved["definitions"].(map[string]interface{}); ok {
+			for key, value := range existing {
+				definitions[key] = value
+			}
+		}
+		for key, value := range b.definitions {
+			definitions[key] = value
+		}
+		resolved["definitions"] = definitions
+	}
+	return resolved, nil
+}
+
+// ResolvedJSON returns the output of Resolve as JSON.
+func (s *SchemaService) ResolvedJSON(schema *Schema) ([]byte, error) {
+	doc, err := s.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// bundler collects the referenced schemas while rewriting a document.
+type bundler struct {
+	service     *SchemaService
+	definitions map[string]interface{}
+	err         error
+}
+
+// rewrite copies node, replacing registry references with local ones. Local
+// references inside a referenced schema are moved below its prefix.
+func (b *bundler) rewrite(node interface{}, prefix string) interface{} {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			if s, ok := value.(string); ok && key == "$ref" {
+				copied[key] = b.rewriteRef(s, prefix)
+				continue
+			}
+			copied[key] = b.rewrite(value, prefix)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, value := range node {
+			copied[i] = b.rewrite(value, prefix)
+		}
+		return copied
+	}
+	return node
+}
+
+func (b *bundler) rewriteRef(ref, prefix string) string {
+	if registryRef, ok, err := parseReference(ref); ok {
+		if err != nil {
+			b.fail(err)
+			return ref
+		}
+		return "#/definitions/" + escapePointer(b.include(registryRef))
+	}
+	if prefix == "" {
+		return ref
+	}
+	switch {
+	case ref == "#":
+		return "#/definitions/" + escapePointer(prefix)
+	case strings.HasPrefix(ref, "#/definitions/"):
+		return "#/definitions/" + escapePointer(prefix) + "." + strings.TrimPrefix(ref, "#/definitions/")
+	case strings.HasPrefix(ref, "#/$defs/"):
+		return "#/definitions/" + escapePointer(prefix) + "." + strings.TrimPrefix(ref, "#/$defs/")
+	}
+	return ref
+}
+
+// include adds the referenced schema to the definitions once and returns its key.
+func (b *bundler) include(ref Reference) string {
+	key := fmt.Sprintf("%s_v%d", ref.Name, ref.Version)
+	if _, ok := b.definitions[key]; ok {
+		return key
+	}
+	// Reserve the key first so references back to this schema terminate
+	b.definitions[key] = nil
+
+	schema, err := b.service.store.FindByNameAndVersion(ref.Name, ref.Version)
+	if err != nil {
+		if err == ErrNotFound {
+			err = fmt.Errorf("%w: %s is not registered", ErrInvalidReference, ref)
+		}
+		b.fail(err)
+		return key
+	}
+	doc, err := schema.Document()
+	if err != nil {
+		b.fail(err)
+		return key
+	}
+
+	for _, keyword := range []string{"definitions", "$defs"} {
+		if defs, ok := doc[keyword].(map[string]interface{}); ok {
+			for name, def := range defs {
+				b.definitions[key+"."+name] = b.rewrite(def, key)
+			}
+		}
+		delete(doc, keyword)
+	}
+	// These would change how references inside the copy are resolved
+	delete(doc, "$id")
+	delete(doc, "$schema")
+	b.definitions[key] = b.rewrite(doc, key)
+	return key
+}
+
+func (b *bundler) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestReferences(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "address", `{"type": "object", "properties": {"street": {"type": "string"}}, "required": ["street"]}`)
+	order := register(t, s, "order", `{"type": "object", "properties": {
+		"from": {"$ref": "registry:address/1"},
+		"to": {"$ref": "registry:address/1"}}}`)
+
+	if want := []Reference{{Name: "address", Version: 1}}; !reflect.DeepEqual(order.References, want) {
+		t.Errorf("references = %v, want %v", order.References, want)
+	}
+	dependents, err := s.FindReferencedBy("address", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dependents) != 1 || dependents[0].Name != "order" || dependents[0].Version != 1 {
+		t.Errorf("got dependents %v, want order 1", dependents)
+	}
+	if _, err := s.FindReferencedBy("address", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("dependents of a missing version: got %v, want ErrNotFound", err)
+	}
+
+	// The registry references are bundled as local definitions
+	resolved, err := s.Resolve(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions, _ := resolved["definitions"].(map[string]interface{})
+	if _, ok := definitions["address_v1"]; !ok {
+		t.Errorf("resolved schema %v has no address_v1 definition", resolved)
+	}
+}
+
+func TestInvalidReference(t *testing.T) {
+	s := newTestService(t)
+	if _, err := tryRegister(s, &Schema{Name: "status", SchemaType: PROTOBUF}, `syntax = "proto3"; message Status { string code = 1; }`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing", body: `{"type": "object", "properties": {"to": {"$ref": "registry:address/1"}}}`},
+		{name: "not JSON", body: `{"type": "object", "properties": {"status": {"$ref": "registry:status/1"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tryRegister(s, &Schema{Name: "order"}, tt.body); !errors.Is(err, ErrInvalidReference) {
+				t.Errorf("got %v, want ErrInvalidReference", err)
+			}
+		})
+	}
+}
+
+func TestDiffThroughRegistryRef(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "address", `{"type": "object", "properties": {"zip": {"type": "string"}}}`)
+	register(t, s, "address", `{"type": "object", "properties": {"zip": {"type": "integer"}}}`)
+	register(t, s, "order", `{"type": "object", "properties": {"to": {"$ref": "registry:address/1"}}}`)
+	register(t, s, "order", `{"type": "object", "properties": {"to": {"$ref": "registry:address/2"}}}`)
+	register(t, s, "order", `{"type": "object", "properties": {"to": {"type": "object", "properties": {"zip": {"type": "integer"}}}}}`)
+	if err := s.SetConfig("order", &Config{Compatibility: compat.Backward}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, changes, violations, err := s.Diff("order", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) == 0 {
+		t.Fatal("expected violations")
+	}
+	// The referenced versions are bundled as different definitions, which
+	// are compared where the references are
+	breaking := map[string]bool{}
+	for _, change := range changes {
+		breaking[change.Kind+" "+change.Path] = change.Breaking
+	}
+	for _, key := range []string{compat.RefChanged + " #/properties/to", compat.TypeChanged + " #/properties/to/properties/zip"} {
+		if b, ok := breaking[key]; !ok || !b {
+			t.Errorf("no breaking %s in %+v", key, changes)
+		}
+	}
+
+	// Inlining the referenced version changes nothing
+	_, changes, _, err = s.Diff("order", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if change.Kind != compat.DefinitionRemoved {
+			t.Errorf("got %s at %s, want only removed definitions", change.Kind, change.Path)
+		}
+	}
+}
@@ -1,147 +1,578 @@
 package service
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/tradeface/schema-registry/internal/compat"
 )
 
 type Schema struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Name      string             `bson:"name"`
-	Version   int                `bson:"version"`
-	Schema    bson.M             `bson:"schema"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+	// SchemaID identifies the content of the schema. It is shared by every
+	// version, under any name, with the same content.
+	SchemaID   int        `bson:"schema_id,omitempty"`
+	Name       string     `bson:"name"`
+	Version    int        `bson:"version"`
+	SchemaType SchemaType `bson:"schema_type,omitempty"`
+	// Draft is the JSON Schema draft of JSON schemas, from their $schema
+	Draft  Draft  `bson:"draft,omitempty"`
+	Schema bson.M `bson:"schema"`
+	// Source holds Avro and Protobuf schemas, which are not stored as a
+	// document
+	Source string `bson:"source,omitempty"`
+	// Fingerprint is the hex SHA-256 of the canonical form, see Canonical
+	Fingerprint string      `bson:"fingerprint,omitempty"`
+	References  []Reference `bson:"references,omitempty"`
+	Metadata    Metadata    `bson:"metadata"`
+	// State is the lifecycle state, see Lifecycle. Deprecation is set while
+	// the state is StateDeprecated.
+	State       State        `bson:"state,omitempty"`
+	Deprecation *Deprecation `bson:"deprecation,omitempty"`
+	// CreatedAt and UpdatedAt are when the version was registered
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	// Deleted marks a soft-deleted version. It is hidden from reads but
+	// keeps its version number and schema id.
+	Deleted   bool       `bson:"deleted,omitempty" json:",omitempty"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:",omitempty"`
+}
+
+// JSON returns the schema body as relaxed extended JSON, which for schemas
+// submitted as plain JSON is plain JSON again.
+func (s *Schema) JSON() ([]byte, error) {
+	return bson.MarshalExtJSON(s.Schema, false, false)
+}
+
+// Document returns the schema body decoded into plain Go values.
+func (s *Schema) Document() (map[string]interface{}, error) {
+	schemaJSON, err := s.JSON()
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(schemaJSON, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
 }
 
+// VersionInfo describes a version without its schema body. Like on Schema,
+// CreatedAt and UpdatedAt are when the version was registered.
+type VersionInfo struct {
+	Version   int       `bson:"version"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	State     State     `bson:"state,omitempty"`
+	Deleted   bool      `bson:"deleted,omitempty"`
+}
+
+// CompatibilityError is returned when a new version breaks the compatibility
+// level configured for its name.
+type CompatibilityError struct {
+	Level      compat.Level
+	Violations []compat.Violation
+}
+
+func (e *CompatibilityError) Error() string {
+	return fmt.Sprintf("schema is not %s compatible: %d violation(s)", e.Level, len(e.Violations))
+}
+
+// LatestVersion selects the latest version where a version number is expected.
+const LatestVersion = -1
+
+// maxVersionAttempts bounds the attempts of Update to allocate a version
+// while other writers store versions of the same name.
+const maxVersionAttempts = 5
+
+var (
+	// ErrSchemaExists is returned by Create when the name is already registered.
+	ErrSchemaExists = errors.New("schema already exists")
+	// ErrReservedName is returned by Create for names that clash with
+	// routes, see reservedNames.
+	ErrReservedName = errors.New("schema name is reserved")
+	// ErrNotSoftDeleted is returned when permanently deleting a version that
+	// has not been soft-deleted first.
+	ErrNotSoftDeleted = errors.New("schema version must be soft-deleted first")
+)
+
+// reservedNames cannot be registered: GET /schemas/ids/:id would shadow the
+// versions of a schema named "ids".
+var reservedNames = []string{"ids"}
+
 type SchemaService struct {
-	collection *mongo.Collection
+	store SchemaStore
+
+	mu            sync.RWMutex
+	compatibility compat.Level
+
+	// validators caches compiled JSON schemas by document id
+	validators sync.Map
+}
+
+func NewSchemaService(store SchemaStore) *SchemaService {
+	return &SchemaService{
+		store:         store,
+		compatibility: compat.Backward,
+	}
 }
 
-func NewSchemaService(client *mongo.Client, dbName, collectionName string) *SchemaService {
-	collection := client.Database(dbName).Collection(collectionName)
-	return &SchemaService{collection}
+// RegisterMode restricts which names Register adds a version to.
+type RegisterMode int
+
+const (
+	// RegisterAny creates the name or adds a version to it.
+	RegisterAny RegisterMode = iota
+	// RegisterNew only creates names and fails with ErrSchemaExists for a
+	// name that has versions.
+	RegisterNew
+	// RegisterVersion only adds versions and fails with ErrNotFound for a
+	// name without versions.
+	RegisterVersion
+)
+
+// Register stores schema, parsed from body, as a new version of its name and
+// returns it with true. If the name already has a live version with the same
+// content, whatever its formatting, that version is returned with false
+// instead, also when it was stored by a concurrent registration. A new
+// version starts with the metadata of the newest version, drafts included,
+// to which metadata is applied if it is not nil.
+func (s *SchemaService) Register(schema *Schema, body []byte, mode RegisterMode, metadata *MetadataPatch) (*Schema, bool, error) {
+	existing, err := s.FindByContent(schema)
+	if err == nil {
+		return existing, false, nil
+	} else if err != ErrNotFound {
+		return nil, false, err
+	}
+
+	var registered *Schema
+	latest, err := s.FindNewest(schema.Name)
+	switch {
+	case err == ErrNotFound && mode != RegisterVersion:
+		if metadata != nil {
+			metadata.Apply(&schema.Metadata)
+		}
+		registered, err = s.Create(schema, body)
+	case err == nil && mode != RegisterNew:
+		schema.Metadata = latest.Metadata
+		if metadata != nil {
+			metadata.Apply(&schema.Metadata)
+		}
+		registered, err = s.Update(schema)
+	case err == nil:
+		return nil, false, fmt.Errorf("%w: %s", ErrSchemaExists, schema.Name)
+	}
+	if errors.Is(err, ErrSchemaExists) || errors.Is(err, ErrVersionExists) {
+		if existing, findErr := s.FindByContent(schema); findErr == nil {
+			return existing, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return registered, true, nil
 }
 
 func (s *SchemaService) Create(schema *Schema, schemaBytes []byte) (*Schema, error) {
+	for _, name := range reservedNames {
+		if schema.Name == name {
+			return nil, fmt.Errorf("%w: %s", ErrReservedName, schema.Name)
+		}
+	}
 
-	// Check if a schema with the same name and version already exists
-	var existingSchema Schema
-	err := s.collection.FindOne(
-		context.Background(),
-		bson.M{"name": schema.Name},
-	).Decode(&existingSchema)
+	// Check if a schema with the same name already exists, drafts included
+	_, err := s.FindNewest(schema.Name)
 	if err == nil {
-		return nil, fmt.Errorf("schema with name %s already exists", schema.Name)
-	} else if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaExists, schema.Name)
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
+	// Numbers of soft-deleted versions are not reused
+	deleted, err := s.store.FindVersions(schema.Name, true)
+	if err != nil {
 		return nil, err
 	}
 
 	schema.CreatedAt = time.Now()
 	schema.UpdatedAt = time.Now()
 	schema.Version = 1
+	if len(deleted) > 0 {
+		schema.Version = deleted[len(deleted)-1].Version + 1
+	}
+	schema.SchemaType = schema.Type()
+	schema.initState()
 
-	// Convert incoming JSON string to bson.M document
-	var schemaDoc bson.M
-	err = bson.UnmarshalExtJSON(schemaBytes, true, &schemaDoc)
+	err = schema.Parse(schemaBytes)
 	if err != nil {
 		return nil, err
 	}
-	schema.Schema = schemaDoc
 
-	res, err := s.collection.InsertOne(context.Background(), schema)
+	schema.References, err = s.references(schema)
 	if err != nil {
 		return nil, err
 	}
-	schema.ID = res.InsertedID.(primitive.ObjectID)
 
-	return schema, nil
+	schema.SchemaID, err = s.schemaID(schema)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.store.Create(schema)
+	if err == ErrVersionExists {
+		// A concurrent Create of the same name won
+		return nil, fmt.Errorf("%w: %s", ErrSchemaExists, schema.Name)
+	}
+	return created, err
 }
 
-func (s *SchemaService) FindAll() ([]*Schema, error) {
-	cursor, err := s.collection.Find(context.Background(), bson.M{})
+// FindAll returns every version of every name, soft-deleted ones only if
+// includeDeleted is set.
+func (s *SchemaService) FindAll(includeDeleted bool) ([]*Schema, error) {
+	return s.store.FindAll(includeDeleted)
+}
+
+func (s *SchemaService) FindByID(id string) (*Schema, error) {
+	return s.store.FindByID(id)
+}
+
+// FindBySchemaID returns a version with the given schema id. Versions sharing
+// an id have the same content but not the same state, so a live version in
+// the best state is preferred, the first by name and version if several are. Soft-deleted
+// versions are included, as data written with their id may still need to be
+// read.
+func (s *SchemaService) FindBySchemaID(id int) (*Schema, error) {
+	return s.store.FindBySchemaID(id)
+}
+
+// FindByName returns the latest version of name that is not a draft.
+func (s *SchemaService) FindByName(name string) (*Schema, error) {
+	return s.store.FindByName(name)
+}
+
+func (s *SchemaService) FindByNameAndVersion(name string, version int) (*Schema, error) {
+	return s.store.FindByNameAndVersion(name, version)
+}
+
+// FindVersions returns every version of name, oldest first, soft-deleted ones
+// only if includeDeleted is set.
+func (s *SchemaService) FindVersions(name string, includeDeleted bool) ([]*Schema, error) {
+	return s.store.FindVersions(name, includeDeleted)
+}
+
+// FindNames returns the names of all schemas, sorted, including those whose
+// versions are all soft-deleted only if includeDeleted is set.
+func (s *SchemaService) FindNames(includeDeleted bool) ([]string, error) {
+	return s.store.FindNames(includeDeleted)
+}
+
+// FindVersionInfos returns the versions of name, oldest first, or ErrNotFound
+// if it has none.
+func (s *SchemaService) FindVersionInfos(name string, includeDeleted bool) ([]VersionInfo, error) {
+	versions, err := s.store.FindVersionInfos(name, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions, nil
+}
 
-	schemas := []*Schema{}
-	for cursor.Next(context.Background()) {
-		schema := &Schema{}
-		err := cursor.Decode(schema)
-		if err != nil {
-			return nil, err
+// FindByContent returns the latest version of schema.Name with the same type
+// and fingerprint as schema, or ErrNotFound if none matches.
+func (s *SchemaService) FindByContent(schema *Schema) (*Schema, error) {
+	matches, err := s.store.FindByFingerprint(schema.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	var found *Schema
+	for _, match := range matches {
+		if match.Deleted || match.Name != schema.Name || match.Type() != schema.Type() {
+			continue
 		}
-		schemas = append(schemas, schema)
+		if found == nil || match.Version > found.Version {
+			found = match
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
 	}
-	return schemas, nil
+	return found, nil
 }
 
-func (s *SchemaService) FindByID(id string) (*Schema, error) {
-	objID, err := primitive.ObjectIDFromHex(id)
+// schemaID returns the schema id of a stored version with the same content
+// under any name, or the id the store keeps for the content, which it
+// allocates on first use. Only call it once a version is about to be stored,
+// so that rejected versions do not use up ids.
+func (s *SchemaService) schemaID(schema *Schema) (int, error) {
+	matches, err := s.store.FindByFingerprint(schema.Fingerprint)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	schema := &Schema{}
-	err = s.collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(schema)
+	for _, match := range matches {
+		if match.SchemaID != 0 && match.Type() == schema.Type() {
+			return match.SchemaID, nil
+		}
+	}
+	return s.store.SchemaID(string(schema.Type()) + ":" + schema.Fingerprint)
+}
+
+// CheckCompatibility compares schema against the stored versions of its name
+// using the configured level and returns the level and the violations found.
+func (s *SchemaService) CheckCompatibility(schema *Schema) (compat.Level, []compat.Violation, error) {
+	versions, err := s.store.FindVersions(schema.Name, false)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
-	return schema, nil
+	return s.checkCompatibility(schema, versions)
 }
 
-func (s *SchemaService) FindByName(name string) (*Schema, error) {
-	opts := options.FindOne().SetSort(bson.M{"version": -1})
-	schema := &Schema{}
-	err := s.collection.FindOne(context.Background(), bson.M{"name": name}, opts).Decode(schema)
+// TestCompatibility is like CheckCompatibility but treats version as the
+// latest one, ignoring newer versions. Pass LatestVersion to check against
+// all stored versions. It returns ErrNotFound if the version does not exist.
+func (s *SchemaService) TestCompatibility(schema *Schema, version int) (compat.Level, []compat.Violation, error) {
+	versions, err := s.store.FindVersions(schema.Name, false)
 	if err != nil {
-		return nil, err
+		return "", nil, err
+	}
+	if version != LatestVersion {
+		for i, v := range versions {
+			if v.Version == version {
+				versions = versions[:i+1]
+				break
+			}
+		}
 	}
-	return schema, nil
+	if len(versions) == 0 || (version != LatestVersion && versions[len(versions)-1].Version != version) {
+		return "", nil, ErrNotFound
+	}
+	return s.checkCompatibility(schema, versions)
 }
 
-func (s *SchemaService) FindByNameAndVersion(name string, version int) (*Schema, error) {
-	query := bson.M{
-		"name":    name,
-		"version": version,
+func (s *SchemaService) checkCompatibility(schema *Schema, versions []*Schema) (compat.Level, []compat.Violation, error) {
+	level, err := s.Compatibility(schema.Name)
+	if err != nil {
+		return "", nil, err
 	}
-	schema := &Schema{}
-	err := s.collection.FindOne(context.Background(), query).Decode(schema)
+	if level == compat.None || len(versions) == 0 {
+		return level, nil, nil
+	}
+
+	latest := versions[len(versions)-1]
+	if latest.Type() != schema.Type() {
+		return level, []compat.Violation{{
+			Type:    compat.SchemaTypeChanged,
+			Path:    "#",
+			Message: fmt.Sprintf("schema type changed from %s to %s", latest.Type(), schema.Type()),
+		}}, nil
+	}
+	// Versions of another type predate a change of type made with
+	// compatibility NONE and are not compared
+	sameType := make([]*Schema, 0, len(versions))
+	for _, version := range versions {
+		if version.Type() == schema.Type() {
+			sameType = append(sameType, version)
+		}
+	}
+	versions = sameType
+
+	switch schema.Type() {
+	case AVRO, PROTOBUF:
+		previous := make([]string, 0, len(versions))
+		for _, version := range versions {
+			previous = append(previous, version.Source)
+		}
+		check := compat.CheckAvro
+		if schema.Type() == PROTOBUF {
+			check = compat.CheckProtobuf
+		}
+		violations, err := check(level, schema.Source, previous)
+		return level, violations, err
+	}
+
+	candidate, err := s.Resolve(schema)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("schema with name '%s' and version '%d' not found", name, version)
+		return "", nil, err
+	}
+	previous := make([]map[string]interface{}, 0, len(versions))
+	for _, version := range versions {
+		doc, err := s.Resolve(version)
+		if err != nil {
+			return "", nil, err
 		}
-		return nil, err
+		previous = append(previous, doc)
 	}
-	return schema, nil
+	return level, compat.Check(level, candidate, previous), nil
 }
 
+// Update stores schema as the next version of its name. The version is
+// allocated from the latest stored one, soft-deleted or not; when a concurrent
+// Update stores that version first, the compatibility check and allocation are
+// retried against the new latest version, up to maxVersionAttempts times.
 func (s *SchemaService) Update(schema *Schema) (*Schema, error) {
-	// We are not actually updating, we insert the schema with a higher version number
-	// Unset the ID to force insertion of a new document
-	schema.ID = primitive.NilObjectID
-	schema.UpdatedAt = time.Now()
-	schema.Version++
-	res, err := s.collection.InsertOne(context.Background(), schema)
+	err := schema.setFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	schema.References, err = s.references(schema)
 	if err != nil {
 		return nil, err
 	}
-	schema.ID = res.InsertedID.(primitive.ObjectID)
-	return schema, nil
+	schema.initState()
+
+	for attempt := 0; attempt < maxVersionAttempts; attempt++ {
+		all, err := s.store.FindVersions(schema.Name, true)
+		if err != nil {
+			return nil, err
+		}
+		versions := make([]*Schema, 0, len(all))
+		for _, version := range all {
+			if !version.Deleted {
+				versions = append(versions, version)
+			}
+		}
+		if len(versions) == 0 {
+			return nil, ErrNotFound
+		}
+		level, violations, err := s.checkCompatibility(schema, versions)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) > 0 {
+			return nil, &CompatibilityError{Level: level, Violations: violations}
+		}
+
+		// We are not actually updating, we insert the schema with a higher version number
+		schema.CreatedAt = time.Now()
+		schema.UpdatedAt = schema.CreatedAt
+		schema.Version = all[len(all)-1].Version + 1
+		if schema.SchemaID == 0 {
+			if schema.SchemaID, err = s.schemaID(schema); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := s.store.Update(schema)
+		if err == ErrVersionExists {
+			continue
+		}
+		return updated, err
+	}
+	return nil, fmt.Errorf("%w: %s/%d was written concurrently, retry the request", ErrVersionExists, schema.Name, schema.Version)
 }
 
-func (s *SchemaService) Delete(id string) error {
-	objID, err := primitive.ObjectIDFromHex(id)
+// DeleteVersion soft-deletes a version of name, or removes it when permanent
+// is set, which requires it to be soft-deleted already. Versions that are
+// referenced by other schemas cannot be deleted; for a permanent delete this
+// includes references from soft-deleted versions.
+func (s *SchemaService) DeleteVersion(name string, version int, permanent bool) error {
+	versions, err := s.store.FindVersions(name, true)
 	if err != nil {
 		return err
 	}
-	filter := bson.M{"_id": objID}
-	_, err = s.collection.DeleteOne(context.Background(), filter)
-	return err
+	for _, schema := range versions {
+		if schema.Version == version {
+			if err := s.checkDelete(schema, permanent, ""); err != nil {
+				return err
+			}
+			return s.delete(schema, permanent)
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteSchema deletes every version of name like DeleteVersion and returns
+// the deleted version numbers. Nothing is deleted if one of the versions
+// cannot be.
+func (s *SchemaService) DeleteSchema(name string, permanent bool) ([]int, error) {
+	versions, err := s.store.FindVersions(name, permanent)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	for _, schema := range versions {
+		if err := s.checkDelete(schema, permanent, name); err != nil {
+			return nil, err
+		}
+	}
+
+	deleted := make([]int, 0, len(versions))
+	for _, schema := range versions {
+		if err := s.delete(schema, permanent); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, schema.Version)
+	}
+	return deleted, nil
+}
+
+func (s *SchemaService) delete(schema *Schema, permanent bool) error {
+	s.validators.Delete(schema.ID)
+	if permanent {
+		return s.store.Delete(schema.ID.Hex())
+	}
+	return s.store.SoftDelete(schema.ID.Hex())
+}
+
+// checkDelete returns why schema cannot be deleted, if it cannot. References
+// from versions of deleting, a name deleted as a whole, are ignored.
+func (s *SchemaService) checkDelete(schema *Schema, permanent bool, deleting string) error {
+	if permanent && !schema.Deleted {
+		return fmt.Errorf("%w: %s/%d", ErrNotSoftDeleted, schema.Name, schema.Version)
+	}
+	if !permanent && schema.Deleted {
+		return ErrNotFound
+	}
+	dependents, err := s.store.FindReferencedBy(schema.Name, schema.Version, permanent)
+	if err != nil {
+		return err
+	}
+	for _, dependent := range dependents {
+		if dependent.Name != deleting {
+			return fmt.Errorf("%w: %s/%d", ErrReferenced, schema.Name, schema.Version)
+		}
+	}
+	return nil
+}
+
+// Diff compares version from of name with version to and classifies every
+// change against the compatibility level of name. It returns ErrNotFound if
+// either version does not exist.
+func (s *SchemaService) Diff(name string, from, to int) (compat.Level, []compat.Change, []compat.Violation, error) {
+	fromSchema, err := s.store.FindByNameAndVersion(name, from)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	toSchema, err := s.store.FindByNameAndVersion(name, to)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, schema := range []*Schema{fromSchema, toSchema} {
+		if schema.Type() != JSON {
+			return "", nil, nil, fmt.Errorf("diff is %w, %s/%d is %s", ErrUnsupportedSchemaType, schema.Name, schema.Version, schema.Type())
+		}
+	}
+
+	level, err := s.Compatibility(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	fromDoc, err := s.Resolve(fromSchema)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	toDoc, err := s.Resolve(toSchema)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	changes, violations := compat.Diff(level, fromDoc, toDoc)
+	return level, changes, violations, nil
 }
@@ -0,0 +1,509 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+// newTestService returns a service on an empty MemoryStore that accepts any
+// new version.
+func newTestService(t *testing.T) *SchemaService {
+	t.Helper()
+	s := NewSchemaService(NewMemoryStore())
+	s.SetDefaultCompatibility(compat.None)
+	return s
+}
+
+// register registers body as a JSON schema of name, creating the name if
+// needed, and returns the stored version.
+func register(t *testing.T, s *SchemaService, name, body string) *Schema {
+	t.Helper()
+	schema, err := tryRegister(s, &Schema{Name: name}, body)
+	if err != nil {
+		t.Fatalf("registering %s %s: %v", name, body, err)
+	}
+	return schema
+}
+
+// tryRegister registers body as a version of schema, which gives its name,
+// type and state.
+func tryRegister(s *SchemaService, schema *Schema, body string) (*Schema, error) {
+	if err := schema.Parse([]byte(body)); err != nil {
+		return nil, err
+	}
+	registered, _, err := s.Register(schema, []byte(body), RegisterAny, nil)
+	return registered, err
+}
+
+func TestRegister(t *testing.T) {
+	owner, tags := "team", []string{"x"}
+	tests := []struct {
+		name     string
+		existing string
+		body     string
+		mode     RegisterMode
+		version  int
+		created  bool
+		err      error
+	}{
+		{name: "new name", body: `{"type": "string"}`, mode: RegisterAny, version: 1, created: true},
+		{name: "new name only", body: `{"type": "string"}`, mode: RegisterNew, version: 1, created: true},
+		{name: "missing name", body: `{"type": "string"}`, mode: RegisterVersion, err: ErrNotFound},
+		{name: "new version", existing: `{"type": "string"}`, body: `{"type": "integer"}`, mode: RegisterAny, version: 2, created: true},
+		{name: "new version only", existing: `{"type": "string"}`, body: `{"type": "integer"}`, mode: RegisterVersion, version: 2, created: true},
+		{name: "existing name", existing: `{"type": "string"}`, body: `{"type": "integer"}`, mode: RegisterNew, err: ErrSchemaExists},
+		{name: "same content", existing: `{"type": "string"}`, body: `{"type":"string"}`, mode: RegisterNew, version: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			if tt.existing != "" {
+				existing := &Schema{Name: "orders"}
+				if err := existing.Parse([]byte(tt.existing)); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := s.Register(existing, []byte(tt.existing), RegisterAny, &MetadataPatch{Owner: &owner}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			schema := &Schema{Name: "orders"}
+			if err := schema.Parse([]byte(tt.body)); err != nil {
+				t.Fatal(err)
+			}
+			registered, created, err := s.Register(schema, []byte(tt.body), tt.mode, &MetadataPatch{Tags: &tags})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if registered.Version != tt.version || created != tt.created {
+				t.Errorf("got version %d, created %v, want version %d, created %v", registered.Version, created, tt.version, tt.created)
+			}
+			if !created {
+				return
+			}
+			// New versions start with the metadata of the newest version
+			if tt.existing != "" && registered.Metadata.Owner != owner {
+				t.Errorf("owner = %q, want %q", registered.Metadata.Owner, owner)
+			}
+			if len(registered.Metadata.Tags) != 1 || registered.Metadata.Tags[0] != "x" {
+				t.Errorf("tags = %v, want [x]", registered.Metadata.Tags)
+			}
+		})
+	}
+}
+
+func TestReservedName(t *testing.T) {
+	s := newTestService(t)
+	if _, err := tryRegister(s, &Schema{Name: "ids"}, `{"type": "string"}`); !errors.Is(err, ErrReservedName) {
+		t.Errorf("got %v, want ErrReservedName", err)
+	}
+}
+
+func TestSchemaIDs(t *testing.T) {
+	s := newTestService(t)
+	a1 := register(t, s, "a", `{"type": "string"}`)
+	a2 := register(t, s, "a", `{"type": "integer"}`)
+	b1 := register(t, s, "b", `{"type":"string"}`)
+
+	if a1.SchemaID == a2.SchemaID {
+		t.Errorf("different content shares schema id %d", a1.SchemaID)
+	}
+	if b1.SchemaID != a1.SchemaID {
+		t.Errorf("same content under another name got id %d, want %d", b1.SchemaID, a1.SchemaID)
+	}
+	found, err := s.FindBySchemaID(a2.SchemaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "a" || found.Version != 2 {
+		t.Errorf("schema id %d found %s/%d, want a/2", a2.SchemaID, found.Name, found.Version)
+	}
+
+	// Rejected registrations do not use up ids
+	s.SetDefaultCompatibility(compat.Backward)
+	if _, err := tryRegister(s, &Schema{Name: "a"}, `{"type": "boolean"}`); err == nil {
+		t.Fatal("registered an incompatible version")
+	}
+	if _, err := tryRegister(s, &Schema{Name: "c"}, `{"$ref": "registry:missing/1"}`); !errors.Is(err, ErrInvalidReference) {
+		t.Fatalf("got %v, want ErrInvalidReference", err)
+	}
+	s.SetDefaultCompatibility(compat.None)
+	a3 := register(t, s, "a", `{"type": "boolean"}`)
+	if a3.SchemaID != a2.SchemaID+1 {
+		t.Errorf("new content got id %d, want %d", a3.SchemaID, a2.SchemaID+1)
+	}
+
+	// Ids are not reused after a permanent delete, and the content keeps
+	// its id
+	if err := s.DeleteVersion("a", 3, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVersion("a", 3, true); err != nil {
+		t.Fatal(err)
+	}
+	a4 := register(t, s, "a", `{"type": "null"}`)
+	if a4.SchemaID <= a3.SchemaID {
+		t.Errorf("new content got id %d, not above %d", a4.SchemaID, a3.SchemaID)
+	}
+	if c1 := register(t, s, "c", `{"type": "boolean"}`); c1.SchemaID != a3.SchemaID {
+		t.Errorf("content of a deleted version got id %d, want %d", c1.SchemaID, a3.SchemaID)
+	}
+}
+
+func TestSchemaIDsConcurrent(t *testing.T) {
+	s := newTestService(t)
+	ids := make([]int, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schema, err := tryRegister(s, &Schema{Name: fmt.Sprintf("s%d", i)}, `{"type": "string"}`)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = schema.SchemaID
+		}(i)
+	}
+	wg.Wait()
+	for _, id := range ids {
+		if id != 1 {
+			t.Fatalf("got schema ids %v for the same content, want 1", ids)
+		}
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "a", `{"type": "string"}`)
+
+	const writers = 8
+	versions := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schema := &Schema{Name: "a"}
+			body := fmt.Sprintf(`{"type": "string", "maxLength": %d}`, i+1)
+			if err := schema.Parse([]byte(body)); err != nil {
+				t.Error(err)
+				return
+			}
+			updated, err := s.Update(schema)
+			if errors.Is(err, ErrVersionExists) {
+				// Every attempt lost against other writers
+				return
+			} else if err != nil {
+				t.Error(err)
+				return
+			}
+			versions <- updated.Version
+		}(i)
+	}
+	wg.Wait()
+	close(versions)
+
+	seen := map[int]bool{}
+	for version := range versions {
+		if seen[version] {
+			t.Errorf("version %d was handed out twice", version)
+		}
+		seen[version] = true
+	}
+	stored, err := s.FindVersions("a", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(seen)+1 {
+		t.Errorf("stored %d versions, want %d", len(stored), len(seen)+1)
+	}
+	for i, schema := range stored {
+		if schema.Version != i+1 {
+			t.Errorf("version %d is stored at position %d", schema.Version, i+1)
+		}
+	}
+}
+
+func TestRegisterDeduplicates(t *testing.T) {
+	avro := `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string"}]}`
+	tests := []struct {
+		name       string
+		schemaType SchemaType
+		bodies     []string
+		want       []int
+	}{
+		{
+			name:   "same content",
+			bodies: []string{`{"type": "string"}`, `{"type": "string"}`},
+			want:   []int{1, 1},
+		},
+		{
+			name:   "whitespace and key order",
+			bodies: []string{`{"type": "object", "properties": {"a": {"type": "string"}}}`, `{"properties":{"a":{"type":"string"}},"type":"object"}`},
+			want:   []int{1, 1},
+		},
+		{
+			name:   "new content",
+			bodies: []string{`{"type": "string"}`, `{"type": "integer"}`},
+			want:   []int{1, 2},
+		},
+		{
+			name:   "earlier content",
+			bodies: []string{`{"type": "string"}`, `{"type": "integer"}`, `{"type": "string"}`},
+			want:   []int{1, 2, 1},
+		},
+		{
+			name:       "avro formatting",
+			schemaType: AVRO,
+			bodies:     []string{avro, `{"name":"R","type":"record","fields":[{"type":"string","name":"a"}]}`},
+			want:       []int{1, 1},
+		},
+		{
+			name:       "avro default added",
+			schemaType: AVRO,
+			bodies:     []string{avro, `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string", "default": ""}]}`},
+			want:       []int{1, 2},
+		},
+		{
+			name:       "avro alias added",
+			schemaType: AVRO,
+			bodies:     []string{avro, `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string", "aliases": ["b"]}]}`},
+			want:       []int{1, 2},
+		},
+		{
+			name:       "protobuf comments",
+			schemaType: PROTOBUF,
+			bodies:     []string{`syntax = "proto3"; message M { string a = 1; }`, "syntax = \"proto3\";\n// M is a message\nmessage M {\n  string a = 1;\n}\n"},
+			want:       []int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			for i, body := range tt.bodies {
+				schema, err := tryRegister(s, &Schema{Name: "s", SchemaType: tt.schemaType}, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if schema.Version != tt.want[i] {
+					t.Errorf("body %d registered as version %d, want %d", i, schema.Version, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "s", `{"type": "string"}`)
+	v2 := register(t, s, "s", `{"type": "integer"}`)
+
+	// Permanent deletes need a soft delete first
+	if err := s.DeleteVersion("s", 2, true); !errors.Is(err, ErrNotSoftDeleted) {
+		t.Errorf("permanent delete of a live version: got %v, want ErrNotSoftDeleted", err)
+	}
+	if err := s.DeleteVersion("s", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVersion("s", 2, false); err != ErrNotFound {
+		t.Errorf("deleting twice: got %v, want ErrNotFound", err)
+	}
+
+	latest, err := s.FindByName("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 1 {
+		t.Errorf("latest version is %d after deleting 2, want 1", latest.Version)
+	}
+	if _, err := s.FindByNameAndVersion("s", 2); err != ErrNotFound {
+		t.Errorf("soft-deleted version: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.FindBySchemaID(v2.SchemaID); err != nil {
+		t.Errorf("soft-deleted version by schema id: %v", err)
+	}
+	if versions, _ := s.FindVersions("s", true); len(versions) != 2 {
+		t.Errorf("got %d versions including deleted ones, want 2", len(versions))
+	}
+
+	// Version numbers of soft-deleted versions are not reused
+	if v3 := register(t, s, "s", `{"type": "boolean"}`); v3.Version != 3 {
+		t.Errorf("registered version %d after deleting 2, want 3", v3.Version)
+	}
+
+	deleted, err := s.DeleteSchema("s", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("deleted versions %v, want 1 and 3", deleted)
+	}
+	if _, err := s.FindByName("s"); err != ErrNotFound {
+		t.Errorf("deleted schema: got %v, want ErrNotFound", err)
+	}
+	if names, _ := s.FindNames(false); len(names) != 0 {
+		t.Errorf("got names %v, want none", names)
+	}
+	if names, _ := s.FindNames(true); len(names) != 1 {
+		t.Errorf("got names %v including deleted ones, want [s]", names)
+	}
+
+	// Registering the name again continues after its deleted versions,
+	// removing them restarts the numbering
+	if v4 := register(t, s, "s", `{"type": "null"}`); v4.Version != 4 {
+		t.Errorf("registered version %d after deleting the schema, want 4", v4.Version)
+	}
+	if _, err := s.DeleteSchema("s", false); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := s.DeleteSchema("s", true); err != nil || len(deleted) != 4 {
+		t.Fatalf("permanent delete: got %v, %v, want 4 versions", deleted, err)
+	}
+	if v1 := register(t, s, "s", `{"type": "string"}`); v1.Version != 1 {
+		t.Errorf("registered version %d after removing the schema, want 1", v1.Version)
+	}
+}
+
+func TestDeleteReferenced(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "address", `{"type": "object", "properties": {"street": {"type": "string"}}}`)
+	register(t, s, "order", `{"type": "object", "properties": {"to": {"$ref": "registry:address/1"}}}`)
+
+	if err := s.DeleteVersion("address", 1, false); !errors.Is(err, ErrReferenced) {
+		t.Errorf("deleting a referenced version: got %v, want ErrReferenced", err)
+	}
+	if _, err := s.DeleteSchema("address", false); !errors.Is(err, ErrReferenced) {
+		t.Errorf("deleting a referenced schema: got %v, want ErrReferenced", err)
+	}
+
+	// A soft-deleted reference still blocks a permanent delete
+	if _, err := s.DeleteSchema("order", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVersion("address", 1, false); err != nil {
+		t.Fatalf("deleting a version referenced by deleted versions only: %v", err)
+	}
+	if err := s.DeleteVersion("address", 1, true); !errors.Is(err, ErrReferenced) {
+		t.Errorf("permanently deleting a version referenced by soft-deleted ones: got %v, want ErrReferenced", err)
+	}
+	if _, err := s.DeleteSchema("order", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVersion("address", 1, true); err != nil {
+		t.Errorf("permanently deleting an unreferenced version: %v", err)
+	}
+}
+
+func TestVersionInfos(t *testing.T) {
+	s := newTestService(t)
+	v1 := register(t, s, "b", `{"type": "string"}`)
+	time.Sleep(time.Millisecond)
+	v2 := register(t, s, "b", `{"type": "integer"}`)
+	register(t, s, "a", `{"type": "string"}`)
+
+	// Every version has its own creation time
+	if !v2.CreatedAt.After(v1.CreatedAt) {
+		t.Errorf("version 2 created at %v, not after version 1 at %v", v2.CreatedAt, v1.CreatedAt)
+	}
+	infos, err := s.FindVersionInfos("b", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Version != 1 || infos[1].Version != 2 {
+		t.Fatalf("got versions %+v, want 1 and 2", infos)
+	}
+	if !infos[0].CreatedAt.Equal(v1.CreatedAt) || !infos[1].CreatedAt.Equal(v2.CreatedAt) {
+		t.Errorf("got creation times %v and %v, want %v and %v", infos[0].CreatedAt, infos[1].CreatedAt, v1.CreatedAt, v2.CreatedAt)
+	}
+	if _, err := s.FindVersionInfos("c", false); err != ErrNotFound {
+		t.Errorf("versions of a missing name: got %v, want ErrNotFound", err)
+	}
+
+	names, err := s.FindNames(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got names %v, want %v", names, want)
+	}
+}
+
+func TestCompatibilityOnUpdate(t *testing.T) {
+	s := newTestService(t)
+	s.SetDefaultCompatibility(compat.Backward)
+	register(t, s, "s", `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`)
+
+	_, err := tryRegister(s, &Schema{Name: "s"}, `{"type": "object", "properties": {"a": {"type": "integer"}}, "additionalProperties": false}`)
+	var compatErr *CompatibilityError
+	if !errors.As(err, &compatErr) {
+		t.Fatalf("got %v, want a CompatibilityError", err)
+	}
+	if compatErr.Level != compat.Backward || len(compatErr.Violations) == 0 {
+		t.Errorf("got %+v", compatErr)
+	}
+	register(t, s, "s", `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, "additionalProperties": false}`)
+}
+
+func TestDiff(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "s", `{"type": "object", "properties": {"a": {"anyOf": [{"type": "string"}, {"type": "integer"}]}}}`)
+	register(t, s, "s", `{"type": "object", "properties": {"a": {"anyOf": [{"type": "string"}]}}}`)
+	register(t, s, "p", `{"type": "array", "items": {"type": "string"}}`)
+	register(t, s, "p", `{"type": "array", "items": {"type": "integer"}}`)
+	if _, err := tryRegister(s, &Schema{Name: "a", SchemaType: AVRO}, `"string"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tryRegister(s, &Schema{Name: "a", SchemaType: AVRO}, `"int"`); err != nil {
+		t.Fatal(err)
+	}
+	s.SetDefaultCompatibility(compat.Backward)
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     []compat.Change
+		err      error
+	}{
+		{name: "s", from: 1, to: 2, want: []compat.Change{{Kind: compat.SubschemaRemoved, Path: "#/properties/a/anyOf/1", Breaking: true}}},
+		{name: "s", from: 2, to: 2, want: []compat.Change{}},
+		{name: "p", from: 1, to: 2, want: []compat.Change{{Kind: compat.TypeChanged, Path: "#/items", Breaking: true}}},
+		{name: "s", from: 1, to: 3, err: ErrNotFound},
+		{name: "missing", from: 1, to: 2, err: ErrNotFound},
+		{name: "a", from: 1, to: 2, err: ErrUnsupportedSchemaType},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d to %d", tt.name, tt.from, tt.to), func(t *testing.T) {
+			level, changes, _, err := s.Diff(tt.name, tt.from, tt.to)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if level != compat.Backward {
+				t.Errorf("got level %s, want BACKWARD", level)
+			}
+			got := []compat.Change{}
+			for _, change := range changes {
+				got = append(got, compat.Change{Kind: change.Kind, Path: change.Path, Breaking: change.Breaking})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
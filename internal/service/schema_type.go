@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/emicklei/proto"
+	"github.com/linkedin/goavro/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SchemaType is the language a schema is written in.
+type SchemaType string
+
+const (
+	JSON     SchemaType = "JSON"
+	AVRO     SchemaType = "AVRO"
+	PROTOBUF SchemaType = "PROTOBUF"
+)
+
+var schemaTypes = []SchemaType{JSON, AVRO, PROTOBUF}
+
+// ErrInvalidSchema is returned when a schema cannot be parsed as its type.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// ParseSchemaType parses a schema type name, ignoring case. The empty string
+// is JSON, the type of schemas stored before types were introduced.
+func ParseSchemaType(s string) (SchemaType, error) {
+	if s == "" {
+		return JSON, nil
+	}
+	for _, t := range schemaTypes {
+		if strings.EqualFold(s, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid schema type: %s", s)
+}
+
+// Type returns the type of the schema, JSON if none was recorded.
+func (s *Schema) Type() SchemaType {
+	if s.SchemaType == "" {
+		return JSON
+	}
+	return s.SchemaType
+}
+
+// Parse validates body as a schema of the type of s and sets it as the body of
+// s, along with its fingerprint. JSON schemas are stored as a document, the
+// others as their source text. JSON schemas are validated against the
+// meta-schema of the draft named by their $schema, which is recorded in Draft.
+func (s *Schema) Parse(body []byte) error {
+	switch s.Type() {
+	case AVRO:
+		if _, err := goavro.NewCodec(string(body)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+		s.Schema, s.Source, s.Draft = nil, string(body), ""
+	case PROTOBUF:
+		if _, err := proto.NewParser(strings.NewReader(string(body))).Parse(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+		s.Schema, s.Source, s.Draft = nil, string(body), ""
+	default:
+		var schemaDoc bson.M
+		if err := bson.UnmarshalExtJSON(body, true, &schemaDoc); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+		s.Schema, s.Source = schemaDoc, ""
+		doc, err := s.Document()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+		if s.Draft, err = checkDraft(doc); err != nil {
+			return err
+		}
+	}
+	return s.setFingerprint()
+}
+
+// Text returns the schema as submitted: the source of Avro and Protobuf
+// schemas, the JSON of JSON schemas.
+func (s *Schema) Text() ([]byte, error) {
+	if s.Type() != JSON {
+		return []byte(s.Source), nil
+	}
+	return s.JSON()
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// State is the lifecycle state of a version.
+type State string
+
+const (
+	// StateDraft versions are registered but not yet released: FindByName
+	// skips them, so they are never served as the latest version.
+	StateDraft State = "draft"
+	// StateActive versions are served normally.
+	StateActive State = "active"
+	// StateDeprecated versions are still served, with their Deprecation.
+	StateDeprecated State = "deprecated"
+	// StateDisabled versions are kept but no longer served.
+	StateDisabled State = "disabled"
+)
+
+var states = []State{StateDraft, StateActive, StateDeprecated, StateDisabled}
+
+// transitions lists the states each state can change to. No version goes
+// back to draft once released.
+var transitions = map[State][]State{
+	StateDraft:      {StateDraft, StateActive, StateDisabled},
+	StateActive:     {StateActive, StateDeprecated, StateDisabled},
+	StateDeprecated: {StateActive, StateDeprecated, StateDisabled},
+	StateDisabled:   {StateActive, StateDeprecated, StateDisabled},
+}
+
+// ErrInvalidState is returned for an unknown state, a state a version cannot
+// be registered in or a transition that is not allowed.
+var ErrInvalidState = errors.New("invalid state")
+
+// Deprecation describes why and until when a deprecated version is served.
+// ReplacedBy points at the version consumers should move to, if any.
+type Deprecation struct {
+	DeprecatedAt time.Time  `bson:"deprecated_at"`
+	Sunset       time.Time  `bson:"sunset,omitempty"`
+	ReplacedBy   *Reference `bson:"replaced_by,omitempty"`
+}
+
+// StateChange is a requested transition. Sunset and ReplacedBy are only
+// accepted when deprecating.
+type StateChange struct {
+	State      State      `json:"state"`
+	Sunset     time.Time  `json:"sunset"`
+	ReplacedBy *Reference `json:"replacedBy"`
+}
+
+// ParseState parses a state name, ignoring case. The empty string is
+// StateActive, the state of versions stored before states were introduced.
+func ParseState(s string) (State, error) {
+	if s == "" {
+		return StateActive, nil
+	}
+	for _, state := range states {
+		if strings.EqualFold(s, string(state)) {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidState, s)
+}
+
+// Lifecycle returns the state of the version, StateActive if none was
+// recorded.
+func (s *Schema) Lifecycle() State {
+	if s.State == "" {
+		return StateActive
+	}
+	return s.State
+}
+
+// initState prepares the state of a version about to be stored. New versions
+// are active unless registered as drafts; a version copied from the latest
+// one does not inherit its deprecation.
+func (s *Schema) initState() {
+	if s.State != StateDraft {
+		s.State = StateActive
+	}
+	s.Deprecation = nil
+}
+
+// FindNewest returns the highest live version of name. Unlike FindByName it
+// includes drafts.
+func (s *SchemaService) FindNewest(name string) (*Schema, error) {
+	versions, err := s.store.FindVersions(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions[len(versions)-1], nil
+}
+
+// SetState moves a version of name to the state of change and returns it.
+// Deprecating a deprecated version updates its sunset and replacement but
+// keeps the time it was deprecated at. The replacement must be a live version
+// that is neither a draft nor disabled.
+func (s *SchemaService) SetState(name string, version int, change StateChange) (*Schema, error) {
+	schema, err := s.store.FindByNameAndVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	from := schema.Lifecycle()
+	if !containsState(transitions[from], change.State) {
+		return nil, fmt.Errorf("%w: %s/%d cannot change from %s to %s", ErrInvalidState, name, version, from, change.State)
+	}
+
+	var deprecation *Deprecation
+	if change.State == StateDeprecated {
+		deprecation = &Deprecation{DeprecatedAt: time.Now(), Sunset: change.Sunset, ReplacedBy: change.ReplacedBy}
+		if schema.Deprecation != nil {
+			deprecation.DeprecatedAt = schema.Deprecation.DeprecatedAt
+		}
+		if ref := change.ReplacedBy; ref != nil {
+			if ref.Name == "" {
+				ref.Name = name
+			}
+			if ref.Name == name && ref.Version == version {
+				return nil, fmt.Errorf("%w: %s/%d cannot replace itself", ErrInvalidState, name, version)
+			}
+			replacement, err := s.store.FindByNameAndVersion(ref.Name, ref.Version)
+			if err == ErrNotFound {
+				return nil, fmt.Errorf("%w: replacement %s/%d not found", ErrInvalidState, ref.Name, ref.Version)
+			} else if err != nil {
+				return nil, err
+			}
+			if state := replacement.Lifecycle(); state == StateDraft || state == StateDisabled {
+				return nil, fmt.Errorf("%w: replacement %s/%d is %s", ErrInvalidState, ref.Name, ref.Version, state)
+			}
+		}
+	} else if !change.Sunset.IsZero() || change.ReplacedBy != nil {
+		return nil, fmt.Errorf("%w: sunset and replacedBy are only accepted when deprecating", ErrInvalidState)
+	}
+
+	if err := s.store.SetState(schema.ID.Hex(), change.State, deprecation); err != nil {
+		return nil, err
+	}
+	schema.State, schema.Deprecation = change.State, deprecation
+	return schema, nil
+}
+
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
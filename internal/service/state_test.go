@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func registerDraft(t *testing.T, s *SchemaService, name, body string) *Schema {
+	t.Helper()
+	schema, err := tryRegister(s, &Schema{Name: name, State: StateDraft}, body)
+	if err != nil {
+		t.Fatalf("registering draft %s %s: %v", name, body, err)
+	}
+	return schema
+}
+
+func TestDraftIsNotLatest(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "orders", `{"type": "string"}`)
+	draft := registerDraft(t, s, "orders", `{"type": "integer"}`)
+	if draft.Version != 2 || draft.Lifecycle() != StateDraft {
+		t.Fatalf("got %s/%d %s, want orders/2 draft", draft.Name, draft.Version, draft.Lifecycle())
+	}
+
+	latest, err := s.FindByName("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 1 {
+		t.Errorf("latest is version %d, want 1", latest.Version)
+	}
+	newest, err := s.FindNewest("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newest.Version != 2 {
+		t.Errorf("newest is version %d, want 2", newest.Version)
+	}
+
+	// A version registered after a draft is active
+	next := register(t, s, "orders", `{"type": "number"}`)
+	if next.Version != 3 || next.Lifecycle() != StateActive {
+		t.Errorf("got version %d %s, want 3 active", next.Version, next.Lifecycle())
+	}
+}
+
+func TestDraftOnly(t *testing.T) {
+	s := newTestService(t)
+	registerDraft(t, s, "orders", `{"type": "string"}`)
+	if _, err := s.FindByName("orders"); err != ErrNotFound {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if _, err := s.SetState("orders", 1, StateChange{State: StateActive}); err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := s.FindByName("orders"); err != nil || latest.Version != 1 {
+		t.Errorf("got %v, %v, want version 1", latest, err)
+	}
+}
+
+func TestSetStateTransitions(t *testing.T) {
+	tests := []struct {
+		from State
+		to   State
+		ok   bool
+	}{
+		{StateDraft, StateDraft, true},
+		{StateDraft, StateActive, true},
+		{StateDraft, StateDeprecated, false},
+		{StateDraft, StateDisabled, true},
+		{StateActive, StateDraft, false},
+		{StateActive, StateDeprecated, true},
+		{StateActive, StateDisabled, true},
+		{StateDeprecated, StateDraft, false},
+		{StateDeprecated, StateActive, true},
+		{StateDeprecated, StateDisabled, true},
+		{StateDisabled, StateDraft, false},
+		{StateDisabled, StateActive, true},
+		{StateDisabled, StateDeprecated, true},
+		{StateActive, "retired", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			s := newTestService(t)
+			if tt.from == StateDraft {
+				registerDraft(t, s, "orders", `{"type": "string"}`)
+			} else {
+				register(t, s, "orders", `{"type": "string"}`)
+				if _, err := s.SetState("orders", 1, StateChange{State: tt.from}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			schema, err := s.SetState("orders", 1, StateChange{State: tt.to})
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidState) {
+					t.Errorf("got %v, want ErrInvalidState", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := s.FindByNameAndVersion("orders", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Lifecycle() != tt.to || stored.Lifecycle() != tt.to {
+				t.Errorf("got %s, stored %s, want %s", schema.Lifecycle(), stored.Lifecycle(), tt.to)
+			}
+		})
+	}
+}
+
+func TestSetStateDeprecation(t *testing.T) {
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		change StateChange
+		ok     bool
+	}{
+		{"plain", StateChange{State: StateDeprecated}, true},
+		{"sunset", StateChange{State: StateDeprecated, Sunset: sunset}, true},
+		{"replaced by version", StateChange{State: StateDeprecated, ReplacedBy: &Reference{Version: 2}}, true},
+		{"replaced by other name", StateChange{State: StateDeprecated, ReplacedBy: &Reference{Name: "invoices", Version: 1}}, true},
+		{"replaced by itself", StateChange{State: StateDeprecated, ReplacedBy: &Reference{Version: 1}}, false},
+		{"replaced by missing", StateChange{State: StateDeprecated, ReplacedBy: &Reference{Version: 9}}, false},
+		{"replaced by draft", StateChange{State: StateDeprecated, ReplacedBy: &Reference{Version: 3}}, false},
+		{"sunset when disabling", StateChange{State: StateDisabled, Sunset: sunset}, false},
+		{"replacement when activating", StateChange{State: StateActive, ReplacedBy: &Reference{Version: 2}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			register(t, s, "orders", `{"type": "string"}`)
+			register(t, s, "orders", `{"type": "integer"}`)
+			registerDraft(t, s, "orders", `{"type": "number"}`)
+			register(t, s, "invoices", `{"type": "string"}`)
+
+			schema, err := s.SetState("orders", 1, tt.change)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidState) {
+					t.Errorf("got %v, want ErrInvalidState", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change.State != StateDeprecated {
+				return
+			}
+			if schema.Deprecation == nil || schema.Deprecation.DeprecatedAt.IsZero() {
+				t.Fatalf("got deprecation %+v", schema.Deprecation)
+			}
+			if !schema.Deprecation.Sunset.Equal(tt.change.Sunset) {
+				t.Errorf("sunset = %v, want %v", schema.Deprecation.Sunset, tt.change.Sunset)
+			}
+			if ref := tt.change.ReplacedBy; ref != nil {
+				got := schema.Deprecation.ReplacedBy
+				if got == nil || got.Version != ref.Version || got.Name == "" {
+					t.Errorf("replacedBy = %+v, want %+v", got, ref)
+				}
+			}
+		})
+	}
+}
+
+func TestSetStateKeepsDeprecatedAt(t *testing.T) {
+	s := newTestService(t)
+	register(t, s, "orders", `{"type": "string"}`)
+	first, err := s.SetState("orders", 1, StateChange{State: StateDeprecated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deprecatedAt := first.Deprecation.DeprecatedAt
+
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	second, err := s.SetState("orders", 1, StateChange{State: StateDeprecated, Sunset: sunset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Deprecation.DeprecatedAt.Equal(deprecatedAt) {
+		t.Errorf("deprecatedAt = %v, want %v", second.Deprecation.DeprecatedAt, deprecatedAt)
+	}
+	if !second.Deprecation.Sunset.Equal(sunset) {
+		t.Errorf("sunset = %v, want %v", second.Deprecation.Sunset, sunset)
+	}
+
+	// Reactivating drops the deprecation
+	active, err := s.SetState("orders", 1, StateChange{State: StateActive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.Deprecation != nil {
+		t.Errorf("got deprecation %+v after reactivating", active.Deprecation)
+	}
+}
+
+func TestFindBySchemaIDPrefersActive(t *testing.T) {
+	s := newTestService(t)
+	a := register(t, s, "a", `{"type": "string"}`)
+	b := register(t, s, "b", `{"type": "string"}`)
+	if a.SchemaID != b.SchemaID {
+		t.Fatalf("got schema ids %d and %d for the same content", a.SchemaID, b.SchemaID)
+	}
+
+	steps := []struct {
+		name    string
+		version int
+		state   State
+		want    string
+	}{
+		{want: "a"},
+		{name: "a", version: 1, state: StateDisabled, want: "b"},
+		{name: "b", version: 1, state: StateDeprecated, want: "b"},
+		{name: "a", version: 1, state: StateDeprecated, want: "a"},
+		{name: "a", version: 1, state: StateActive, want: "a"},
+		{name: "b", version: 1, state: StateActive, want: "a"},
+	}
+	for i, step := range steps {
+		if step.name != "" {
+			if _, err := s.SetState(step.name, step.version, StateChange{State: step.state}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		schema, err := s.FindBySchemaID(a.SchemaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schema.Name != step.want {
+			t.Errorf("step %d: got %s/%d %s, want %s", i, schema.Name, schema.Version, schema.Lifecycle(), step.want)
+		}
+	}
+}
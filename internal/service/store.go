@@ -0,0 +1,86 @@
+package service
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by a SchemaStore when no document matches the query.
+	ErrNotFound = errors.New("schema not found")
+	// ErrConfigNotFound is returned by a SchemaStore when no config is stored for a name.
+	ErrConfigNotFound = errors.New("config not found")
+	// ErrVersionExists is returned by a SchemaStore when a document with the
+	// same name and version is already stored.
+	ErrVersionExists = errors.New("schema version already exists")
+)
+
+// SchemaStore persists schema documents. Every version of a schema is stored
+// as a separate document. A soft-deleted document is hidden from every query
+// except FindBySchemaID and FindByFingerprint, and from those taking
+// includeDeleted unless it is set. Configs are stored next to the schemas,
+// keyed by schema name; the global config uses the empty name.
+// Implementations must be safe for concurrent use.
+type SchemaStore interface {
+	// Create and Update store a new document. They fail with
+	// ErrVersionExists rather than storing a second document with the same
+	// name and version.
+	Create(schema *Schema) (*Schema, error)
+	FindAll(includeDeleted bool) ([]*Schema, error)
+	FindByID(id string) (*Schema, error)
+	// FindBySchemaID returns the preferred document with the given schema
+	// id, as chosen by preferredVersion.
+	FindBySchemaID(id int) (*Schema, error)
+	// FindByFingerprint returns the documents with the given fingerprint
+	// under any name.
+	FindByFingerprint(fingerprint string) ([]*Schema, error)
+	// FindByName returns the document with the highest version that is not
+	// a draft.
+	FindByName(name string) (*Schema, error)
+	FindByNameAndVersion(name string, version int) (*Schema, error)
+	// FindVersions returns the documents of name ordered by version.
+	FindVersions(name string, includeDeleted bool) ([]*Schema, error)
+	// FindPage returns up to query.Limit documents matching the filters of
+	// query, in its order, positioned after the sort keys of after when it
+	// is not nil.
+	FindPage(query ListQuery, after *Schema) ([]*Schema, error)
+	// FindNames and FindVersionInfos return the sorted names and the
+	// versions of a name without loading the schema bodies.
+	FindNames(includeDeleted bool) ([]string, error)
+	FindVersionInfos(name string, includeDeleted bool) ([]VersionInfo, error)
+	// FindReferencedBy returns the documents whose References contain the
+	// given version.
+	FindReferencedBy(name string, version int, includeDeleted bool) ([]*Schema, error)
+	Update(schema *Schema) (*Schema, error)
+	// SetMetadata replaces the metadata of a document and SetState its state
+	// and deprecation, the only parts of a version that can change.
+	SetMetadata(id string, metadata *Metadata) error
+	SetState(id string, state State, deprecation *Deprecation) error
+	// SoftDelete marks a document as deleted and Delete removes it.
+	SoftDelete(id string) error
+	Delete(id string) error
+	// SchemaID returns the integer schema id of the content identified by
+	// key. The first call for a key allocates the next id, starting at 1;
+	// concurrent calls for the same key return the same id.
+	SchemaID(key string) (int, error)
+
+	FindConfig(name string) (*Config, error)
+	SaveConfig(config *Config) error
+	DeleteConfig(name string) error
+}
+
+// stateRanks orders the states from the most to the least preferred version
+// to serve for a schema id.
+var stateRanks = map[State]int{StateActive: 0, StateDeprecated: 1, StateDraft: 2, StateDisabled: 3}
+
+// preferredVersion returns the version to serve among versions sharing a
+// schema id, which must be ordered by name and version: the first live one
+// in the best state, as soft-deleted and disabled versions are only served
+// when there is nothing else. It returns nil if versions is empty.
+func preferredVersion(versions []*Schema) *Schema {
+	var preferred *Schema
+	for _, version := range versions {
+		if preferred == nil || preferred.Deleted && !version.Deleted ||
+			preferred.Deleted == version.Deleted && stateRanks[version.Lifecycle()] < stateRanks[preferred.Lifecycle()] {
+			preferred = version
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/tradeface/schema-registry/internal/compat"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) SchemaStore {
+		return NewMemoryStore()
+	})
+}
+
+// TestMongoStore runs the store tests against the MongoDB at MONGO_TEST_URI,
+// each in a database that is dropped afterwards.
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+
+	testStore(t, func(t *testing.T) SchemaStore {
+		db := fmt.Sprintf("schema_registry_test_%d", time.Now().UnixNano())
+		t.Cleanup(func() { client.Database(db).Drop(ctx) })
+		store := NewMongoStore(client, db, "schemas")
+		if err := store.EnsureIndexes(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// newVersion returns an unsaved version of name with a string schema.
+func newVersion(name string, version int) *Schema {
+	return &Schema{
+		Name:        name,
+		Version:     version,
+		Schema:      bson.M{"type": "string"},
+		Fingerprint: fmt.Sprintf("%s-%d", name, version),
+		State:       StateActive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
+
+func storeVersions(t *testing.T, store SchemaStore, schemas ...*Schema) []*Schema {
+	t.Helper()
+	stored := make([]*Schema, 0, len(schemas))
+	for _, schema := range schemas {
+		created, err := store.Create(schema)
+		if err != nil {
+			t.Fatalf("storing %s/%d: %v", schema.Name, schema.Version, err)
+		}
+		stored = append(stored, created)
+	}
+	return stored
+}
+
+func versionNumbers(schemas []*Schema) []int {
+	versions := []int{}
+	for _, schema := range schemas {
+		versions = append(versions, schema.Version)
+	}
+	return versions
+}
+
+// testStore checks the behaviour every SchemaStore shares, on a new store for
+// each subtest.
+func testStore(t *testing.T, newStore func(t *testing.T) SchemaStore) {
+	t.Run("versions", func(t *testing.T) {
+		store := newStore(t)
+		stored := storeVersions(t, store, newVersion("a", 1), newVersion("b", 1))
+		if _, err := store.Update(newVersion("a", 2)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Update(newVersion("a", 2)); err != ErrVersionExists {
+			t.Errorf("storing a/2 twice: got %v, want ErrVersionExists", err)
+		}
+		if _, err := store.Create(newVersion("b", 1)); err != ErrVersionExists {
+			t.Errorf("storing b/1 twice: got %v, want ErrVersionExists", err)
+		}
+
+		if latest, err := store.FindByName("a"); err != nil || latest.Version != 2 {
+			t.Errorf("FindByName(a) = %v, %v, want version 2", latest, err)
+		}
+		if _, err := store.FindByName("c"); err != ErrNotFound {
+			t.Errorf("FindByName(c): got %v, want ErrNotFound", err)
+		}
+		if schema, err := store.FindByNameAndVersion("a", 1); err != nil || schema.Name != "a" || schema.Version != 1 {
+			t.Errorf("FindByNameAndVersion(a, 1) = %v, %v", schema, err)
+		}
+		if _, err := store.FindByNameAndVersion("a", 3); err != ErrNotFound {
+			t.Errorf("FindByNameAndVersion(a, 3): got %v, want ErrNotFound", err)
+		}
+		if schema, err := store.FindByID(stored[1].ID.Hex()); err != nil || schema.Name != "b" {
+			t.Errorf("FindByID = %v, %v, want b", schema, err)
+		}
+		if versions, err := store.FindVersions("a", false); err != nil || !reflect.DeepEqual(versionNumbers(versions), []int{1, 2}) {
+			t.Errorf("FindVersions(a) = %v, %v, want [1 2]", versionNumbers(versions), err)
+		}
+		if versions, err := store.FindVersions("c", false); err != nil || len(versions) != 0 {
+			t.Errorf("FindVersions(c) = %v, %v, want none", versionNumbers(versions), err)
+		}
+		if all, err := store.FindAll(false); err != nil || len(all) != 3 {
+			t.Errorf("FindAll = %d versions, %v, want 3", len(all), err)
+		}
+		if names, err := store.FindNames(false); err != nil || !reflect.DeepEqual(names, []string{"a", "b"}) {
+			t.Errorf("FindNames = %v, %v, want [a b]", names, err)
+		}
+		infos, err := store.FindVersionInfos("a", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 2 || infos[0].Version != 1 || infos[1].Version != 2 || infos[1].State != StateActive {
+			t.Errorf("FindVersionInfos(a) = %+v", infos)
+		}
+		if matches, err := store.FindByFingerprint("a-2"); err != nil || len(matches) != 1 || matches[0].Version != 2 {
+			t.Errorf("FindByFingerprint(a-2) = %v, %v", matches, err)
+		}
+	})
+
+	t.Run("concurrent versions", func(t *testing.T) {
+		store := newStore(t)
+		errs := make(chan error, 8)
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				_, err := store.Update(newVersion("a", 1))
+				errs <- err
+			}()
+		}
+		stored := 0
+		for i := 0; i < cap(errs); i++ {
+			switch err := <-errs; err {
+			case nil:
+				stored++
+			case ErrVersionExists:
+			default:
+				t.Error(err)
+			}
+		}
+		if stored != 1 {
+			t.Errorf("stored a/1 %d times, want once", stored)
+		}
+	})
+
+	t.Run("drafts", func(t *testing.T) {
+		store := newStore(t)
+		draft := newVersion("a", 2)
+		draft.State = StateDraft
+		storeVersions(t, store, newVersion("a", 1), draft)
+
+		if latest, err := store.FindByName("a"); err != nil || latest.Version != 1 {
+			t.Errorf("FindByName(a) = %v, %v, want version 1", latest, err)
+		}
+		if versions, err := store.FindVersions("a", false); err != nil || len(versions) != 2 {
+			t.Errorf("FindVersions(a) = %v, %v, want both versions", versionNumbers(versions), err)
+		}
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		store := newStore(t)
+		first, second := newVersion("a", 1), newVersion("a", 2)
+		first.SchemaID, second.SchemaID = 1, 2
+		stored := storeVersions(t, store, first, second)
+		id := stored[1].ID.Hex()
+
+		if err := store.SoftDelete(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SoftDelete(id); err != ErrNotFound {
+			t.Errorf("soft-deleting twice: got %v, want ErrNotFound", err)
+		}
+		if latest, err := store.FindByName("a"); err != nil || latest.Version != 1 {
+			t.Errorf("FindByName(a) = %v, %v, want version 1", latest, err)
+		}
+		if _, err := store.FindByNameAndVersion("a", 2); err != ErrNotFound {
+			t.Errorf("FindByNameAndVersion(a, 2): got %v, want ErrNotFound", err)
+		}
+		if _, err := store.FindByID(id); err != ErrNotFound {
+			t.Errorf("FindByID: got %v, want ErrNotFound", err)
+		}
+		if versions, _ := store.FindVersions("a", false); !reflect.DeepEqual(versionNumbers(versions), []int{1}) {
+			t.Errorf("FindVersions(a) = %v, want [1]", versionNumbers(versions))
+		}
+		versions, err := store.FindVersions("a", true)
+		if err != nil || !reflect.DeepEqual(versionNumbers(versions), []int{1, 2}) {
+			t.Fatalf("FindVersions(a, deleted) = %v, %v, want [1 2]", versionNumbers(versions), err)
+		}
+		if !versions[1].Deleted || versions[1].DeletedAt == nil {
+			t.Errorf("a/2 is not marked deleted: %+v", versions[1])
+		}
+		// Data written with the id of a deleted version can still be read
+		if schema, err := store.FindBySchemaID(2); err != nil || schema.Version != 2 {
+			t.Errorf("FindBySchemaID(2) = %v, %v, want a/2", schema, err)
+		}
+		if matches, _ := store.FindByFingerprint("a-2"); len(matches) != 1 {
+			t.Errorf("FindByFingerprint(a-2) = %v, want a/2", matches)
+		}
+
+		if err := store.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(id); err != ErrNotFound {
+			t.Errorf("deleting twice: got %v, want ErrNotFound", err)
+		}
+		if versions, _ := store.FindVersions("a", true); !reflect.DeepEqual(versionNumbers(versions), []int{1}) {
+			t.Errorf("FindVersions(a, deleted) = %v, want [1]", versionNumbers(versions))
+		}
+		if _, err := store.FindBySchemaID(2); err != ErrNotFound {
+			t.Errorf("FindBySchemaID(2): got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("references", func(t *testing.T) {
+		store := newStore(t)
+		dependent := newVersion("b", 1)
+		dependent.References = []Reference{{Name: "a", Version: 1}}
+		stored := storeVersions(t, store, newVersion("a", 1), newVersion("a", 2), dependent)
+
+		if dependents, err := store.FindReferencedBy("a", 1, false); err != nil || len(dependents) != 1 || dependents[0].Name != "b" {
+			t.Errorf("FindReferencedBy(a, 1) = %v, %v, want b/1", dependents, err)
+		}
+		if dependents, _ := store.FindReferencedBy("a", 2, false); len(dependents) != 0 {
+			t.Errorf("FindReferencedBy(a, 2) = %v, want none", dependents)
+		}
+		if err := store.SoftDelete(stored[2].ID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+		if dependents, _ := store.FindReferencedBy("a", 1, false); len(dependents) != 0 {
+			t.Errorf("FindReferencedBy(a, 1) = %v, want none", dependents)
+		}
+		if dependents, _ := store.FindReferencedBy("a", 1, true); len(dependents) != 1 {
+			t.Errorf("FindReferencedBy(a, 1, deleted) = %v, want b/1", dependents)
+		}
+	})
+
+	t.Run("metadata and state", func(t *testing.T) {
+		store := newStore(t)
+		stored := storeVersions(t, store, newVersion("a", 1))
+		id := stored[0].ID.Hex()
+
+		metadata := &Metadata{Owner: "team", Tags: []string{"x"}, Labels: map[string]string{"k": "v"}}
+		if err := store.SetMetadata(id, metadata); err != nil {
+			t.Fatal(err)
+		}
+		deprecation := &Deprecation{DeprecatedAt: time.Now().UTC().Truncate(time.Millisecond)}
+		if err := store.SetState(id, StateDeprecated, deprecation); err != nil {
+			t.Fatal(err)
+		}
+		schema, err := store.FindByNameAndVersion("a", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(schema.Metadata, *metadata) {
+			t.Errorf("metadata = %+v, want %+v", schema.Metadata, *metadata)
+		}
+		if schema.State != StateDeprecated || schema.Deprecation == nil || !schema.Deprecation.DeprecatedAt.Equal(deprecation.DeprecatedAt) {
+			t.Errorf("state = %s %+v", schema.State, schema.Deprecation)
+		}
+
+		missing := primitive.NewObjectID().Hex()
+		if err := store.SetMetadata(missing, metadata); err != ErrNotFound {
+			t.Errorf("SetMetadata of a missing id: got %v, want ErrNotFound", err)
+		}
+		if err := store.SetState(missing, StateActive, nil); err != ErrNotFound {
+			t.Errorf("SetState of a missing id: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		store := newStore(t)
+		tagged := newVersion("b", 1)
+		tagged.Metadata.Tags = []string{"x"}
+		storeVersions(t, store, newVersion("c", 1), newVersion("a", 1), newVersion("a", 2), tagged)
+
+		page, err := store.FindPage(ListQuery{Sort: SortByName, Limit: 2}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].Name != "a" || page[0].Version != 1 || page[1].Version != 2 {
+			t.Fatalf("first page = %v", page)
+		}
+		page, err = store.FindPage(ListQuery{Sort: SortByName, Limit: 2}, page[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].Name != "b" || page[1].Name != "c" {
+			t.Errorf("second page = %v", page)
+		}
+		page, err = store.FindPage(ListQuery{Sort: SortByName, Limit: 10, Tags: []string{"x"}}, nil)
+		if err != nil || len(page) != 1 || page[0].Name != "b" {
+			t.Errorf("tagged page = %v, %v", page, err)
+		}
+	})
+
+	t.Run("schema ids", func(t *testing.T) {
+		store := newStore(t)
+		for i, key := range []string{"a", "b", "a", "c", "b"} {
+			want := map[string]int{"a": 1, "b": 2, "c": 3}[key]
+			if id, err := store.SchemaID(key); err != nil || id != want {
+				t.Errorf("call %d: SchemaID(%s) = %d, %v, want %d", i, key, id, err, want)
+			}
+		}
+
+		// Concurrent calls for a new key agree on its id
+		ids := make([]int, 8)
+		var wg sync.WaitGroup
+		for i := range ids {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id, err := store.SchemaID("d")
+				if err != nil {
+					t.Error(err)
+				}
+				ids[i] = id
+			}(i)
+		}
+		wg.Wait()
+		for _, id := range ids {
+			if id != ids[0] || id <= 3 {
+				t.Fatalf("got ids %v for the same key, want one id above 3", ids)
+			}
+		}
+	})
+
+	t.Run("schema id preference", func(t *testing.T) {
+		store := newStore(t)
+		versions := []*Schema{newVersion("c", 1), newVersion("b", 2), newVersion("b", 1), newVersion("a", 1)}
+		for i, state := range []State{StateActive, StateDisabled, StateDeprecated, StateDraft} {
+			versions[i].SchemaID, versions[i].State = 7, state
+		}
+		stored := storeVersions(t, store, versions...)
+		c1, b2, b1, a1 := stored[0].ID.Hex(), stored[1].ID.Hex(), stored[2].ID.Hex(), stored[3].ID.Hex()
+		if err := store.SoftDelete(c1); err != nil {
+			t.Fatal(err)
+		}
+
+		steps := []struct {
+			change func() error
+			want   string
+		}{
+			// Live versions first, in the best state
+			{nil, "b/1"},
+			{func() error { return store.SetState(b1, StateDisabled, nil) }, "a/1"},
+			// The first by name and version among equals
+			{func() error { return store.SoftDelete(a1) }, "b/1"},
+			// Soft-deleted versions when there is nothing else
+			{func() error {
+				if err := store.SoftDelete(b1); err != nil {
+					return err
+				}
+				return store.SoftDelete(b2)
+			}, "c/1"},
+		}
+		for i, step := range steps {
+			if step.change != nil {
+				if err := step.change(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			schema, err := store.FindBySchemaID(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%s/%d", schema.Name, schema.Version); got != step.want {
+				t.Errorf("step %d: FindBySchemaID(7) = %s, want %s", i, got, step.want)
+			}
+		}
+	})
+
+	t.Run("configs", func(t *testing.T) {
+		store := newStore(t)
+		if _, err := store.FindConfig("a"); err != ErrConfigNotFound {
+			t.Errorf("FindConfig(a): got %v, want ErrConfigNotFound", err)
+		}
+		for _, level := range []compat.Level{compat.Forward, compat.Full} {
+			if err := store.SaveConfig(&Config{Name: "a", Compatibility: level}); err != nil {
+				t.Fatal(err)
+			}
+			if config, err := store.FindConfig("a"); err != nil || config.Compatibility != level {
+				t.Errorf("FindConfig(a) = %v, %v, want %s", config, err, level)
+			}
+		}
+		if err := store.DeleteConfig("a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DeleteConfig("a"); err != ErrConfigNotFound {
+			t.Errorf("deleting twice: got %v, want ErrConfigNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ErrUnsupportedSchemaType is returned for operations that are only supported
+// for JSON schemas, such as validating payloads, on schemas of other types.
+var ErrUnsupportedSchemaType = errors.New("only supported for JSON schemas")
+
+// PayloadError is a violation of a schema by a payload. Pointer is the JSON
+// pointer of the offending value, empty for the payload itself.
+type PayloadError struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// PayloadResult is the outcome of validating the payload at Index of a batch.
+type PayloadResult struct {
+	Index  int            `json:"index"`
+	Valid  bool           `json:"valid"`
+	Errors []PayloadError `json:"errors,omitempty"`
+}
+
+// ValidatePayloads validates each JSON payload against schema. A payload that
+// is not JSON is reported as invalid rather than failing the batch.
+func (s *SchemaService) ValidatePayloads(schema *Schema, payloads [][]byte) ([]PayloadResult, error) {
+	validate, err := s.validator(schema)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]PayloadResult, 0, len(payloads))
+	for i, payload := range payloads {
+		errs := validate(payload)
+		results = append(results, PayloadResult{Index: i, Valid: len(errs) == 0, Errors: errs})
+	}
+	return results, nil
+}
+
+// payloadValidator returns the violations of a schema by a payload.
+type payloadValidator func(payload []byte) []PayloadError
+
+// validator returns the compiled schema of a version. Versions are immutable,
+// so validators are cached by document id until the version is deleted.
+// gojsonschema only knows drafts up to draft-07, so schemas of later drafts
+// are compiled with jsonschema.
+func (s *SchemaService) validator(schema *Schema) (payloadValidator, error) {
+	if schema.Type() != JSON {
+		return nil, fmt.Errorf("payload validation is %w, %s/%d is %s", ErrUnsupportedSchemaType, schema.Name, schema.Version, schema.Type())
+	}
+	if cached, ok := s.validators.Load(schema.ID); ok {
+		return cached.(payloadValidator), nil
+	}
+
+	doc, err := s.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+	draft := schema.Draft
+	if draft == "" {
+		// Stored before drafts were recorded
+		if draft, err = detectDraft(doc); err != nil {
+			return nil, err
+		}
+	}
+	var validate payloadValidator
+	switch draft {
+	case Draft201909, Draft202012:
+		validate, err = jsonschemaValidator(schema, doc)
+	default:
+		validate, err = gojsonschemaValidator(doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.validators.Store(schema.ID, validate)
+	return validate, nil
+}
+
+func gojsonschemaValidator(doc map[string]interface{}) (payloadValidator, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return nil, err
+	}
+	return func(payload []byte) []PayloadError {
+		res, err := compiled.Validate(gojsonschema.NewBytesLoader(payload))
+		if err != nil {
+			return []PayloadError{{Message: err.Error()}}
+		}
+		errs := []PayloadError{}
+		for _, resErr := range res.Errors() {
+			errs = append(errs, PayloadError{
+				Pointer: jsonPointer(resErr.Context()),
+				Message: resErr.Description(),
+			})
+		}
+		return errs
+	}, nil
+}
+
+func jsonschemaValidator(schema *Schema, doc map[string]interface{}) (payloadValidator, error) {
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("registry:%s/%d", schema.Name, schema.Version)
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(docJSON)); err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	return func(payload []byte) []PayloadError {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return []PayloadError{{Message: err.Error()}}
+		}
+		err := compiled.Validate(value)
+		if err == nil {
+			return nil
+		}
+		verr, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return []PayloadError{{Message: err.Error()}}
+		}
+		errs := []PayloadError{}
+		for _, leaf := range validationLeaves(verr) {
+			errs = append(errs, PayloadError{Pointer: leaf.InstanceLocation, Message: leaf.Message})
+		}
+		return errs
+	}, nil
+}
+
+// jsonPointer converts the context of a validation error, "(root)" followed
+// by the keys and indexes of the path, to a JSON pointer.
+func jsonPointer(context *gojsonschema.JsonContext) string {
+	segments := strings.Split(context.String("\x00"), "\x00")[1:]
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	var pointer strings.Builder
+	for _, segment := range segments {
+		pointer.WriteString("/")
+		pointer.WriteString(escaper.Replace(segment))
+	}
+	return pointer.String()
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidatePayloads(t *testing.T) {
+	const properties = `"type": "object",
+		"properties": {
+			"id": {"type": "integer"},
+			"items": {"type": "array", "items": {"type": "string"}},
+			"a/b": {"type": "string"}
+		},
+		"required": ["id"]`
+	drafts := map[string]string{
+		"draft-07": `{"$schema": "http://json-schema.org/draft-07/schema#", ` + properties + `}`,
+		"2020-12":  `{"$schema": "https://json-schema.org/draft/2020-12/schema", ` + properties + `}`,
+	}
+	tests := []struct {
+		name     string
+		payload  string
+		valid    bool
+		pointers []string
+	}{
+		{"valid", `{"id": 1, "items": ["x"]}`, true, nil},
+		{"wrong type", `{"id": "1"}`, false, []string{"/id"}},
+		{"array item", `{"id": 1, "items": ["x", 2]}`, false, []string{"/items/1"}},
+		{"escaped key", `{"id": 1, "a/b": 2}`, false, []string{"/a~1b"}},
+		{"not json", `{"id":`, false, []string{""}},
+	}
+	for draft, body := range drafts {
+		s := newTestService(t)
+		schema := register(t, s, "orders", body)
+		for _, tt := range tests {
+			t.Run(draft+"/"+tt.name, func(t *testing.T) {
+				results, err := s.ValidatePayloads(schema, [][]byte{[]byte(tt.payload)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != 1 {
+					t.Fatalf("got %d results, want 1", len(results))
+				}
+				result := results[0]
+				if result.Valid != tt.valid {
+					t.Errorf("valid = %v, want %v, errors: %v", result.Valid, tt.valid, result.Errors)
+				}
+				var pointers []string
+				for _, e := range result.Errors {
+					pointers = append(pointers, e.Pointer)
+				}
+				if !reflect.DeepEqual(pointers, tt.pointers) {
+					t.Errorf("pointers = %q, want %q", pointers, tt.pointers)
+				}
+			})
+		}
+	}
+}
+
+func TestValidatePayloadsIndexes(t *testing.T) {
+	s := newTestService(t)
+	schema := register(t, s, "orders", `{"type": "integer"}`)
+	results, err := s.ValidatePayloads(schema, [][]byte{[]byte(`1`), []byte(`"a"`), []byte(`2`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false, true} {
+		if results[i].Index != i || results[i].Valid != want {
+			t.Errorf("result %d = %+v, want valid %v", i, results[i], want)
+		}
+	}
+}
+
+func TestValidatePayloadsUnsupported(t *testing.T) {
+	s := newTestService(t)
+	for _, schemaType := range []SchemaType{AVRO, PROTOBUF} {
+		schema := &Schema{Name: "orders", Version: 1, SchemaType: schemaType}
+		if _, err := s.ValidatePayloads(schema, [][]byte{[]byte(`{}`)}); !errors.Is(err, ErrUnsupportedSchemaType) {
+			t.Errorf("%s: got %v, want ErrUnsupportedSchemaType", schemaType, err)
+		}
+	}
+}